@@ -19,16 +19,16 @@ import (
 )
 
 func main() {
-	command := ""
+	if err := zdecli.RunArgs("", os.Args[1:]); err != nil {
+		if msg := err.Error(); msg != "" {
+			log.Printf("Error: %s", msg)
+		}
 
-	if len(os.Args) > 1 {
-		command = os.Args[1]
-	}
-
-	if err := zdecli.Run("", command); err != nil {
-		log.Printf("Error: %s", err)
-		zdecli.PrintUsage()
-		os.Exit(-1)
+		code := zdecli.ExitCode(err)
+		if code == zdecli.ExitUsage {
+			zdecli.PrintUsage()
+		}
+		os.Exit(code)
 	} else {
 		os.Exit(0)
 	}
@@ -0,0 +1,139 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"flag"
+	"io"
+	"strings"
+)
+
+// SplitArgs splits an unparsed argument string into words as a POSIX shell would. Words are separated by whitespace.
+// Single quotes group text literally. Double quotes group text in which only `\"` and `\\` are escapes, so regular
+// expressions such as "\d+" keep their backslashes. Outside quotes a backslash escapes the character that follows it.
+func SplitArgs(args string) []string {
+	var result []string
+	for _, token := range tokenizeArgs(args) {
+		result = append(result, token.value)
+	}
+	return result
+}
+
+// argToken is a word of an argument string along with the offsets of its raw text, quotes included.
+type argToken struct {
+	value string
+	start int
+	end   int
+}
+
+// raw returns the text of the token as it was written in `args`.
+func (token argToken) raw(args string) string {
+	return args[token.start:token.end]
+}
+
+// tokenizeArgs splits `args` into words following the rules of SplitArgs. An unterminated quote extends to the end
+// of `args`.
+func tokenizeArgs(args string) []argToken {
+	var result []argToken
+	var current strings.Builder
+
+	const (
+		unquoted = iota
+		singleQuoted
+		doubleQuoted
+	)
+
+	mode := unquoted
+	start := -1
+	escaped := false
+
+	for i, r := range args {
+		if start == -1 {
+			if mode == unquoted && (r == ' ' || r == '\t') {
+				continue
+			}
+			start = i
+		}
+
+		switch {
+		case escaped:
+			// inside double quotes only \" and \\ are escapes, any other backslash is kept
+			if mode == doubleQuoted && r != '"' && r != '\\' {
+				current.WriteRune('\\')
+			}
+			current.WriteRune(r)
+			escaped = false
+		case mode == singleQuoted:
+			if r == '\'' {
+				mode = unquoted
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+		case mode == doubleQuoted:
+			if r == '"' {
+				mode = unquoted
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"':
+			mode = doubleQuoted
+		case r == '\'':
+			mode = singleQuoted
+		case r == ' ' || r == '\t':
+			result = append(result, argToken{value: current.String(), start: start, end: i})
+			current.Reset()
+			start = -1
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	if start != -1 {
+		if escaped {
+			current.WriteRune('\\')
+		}
+		result = append(result, argToken{value: current.String(), start: start, end: len(args)})
+	}
+
+	return result
+}
+
+// newCommandFlags returns a flag.FlagSet for parsing the arguments of `cmd`. Errors are returned, not printed.
+func newCommandFlags(cmd *Command) *flag.FlagSet {
+	flags := flag.NewFlagSet(cmd.Text, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	return flags
+}
+
+// parseCommandFlags parses `args` with `flags`. Flags may appear before or after positional arguments, which are
+// returned.
+func parseCommandFlags(flags *flag.FlagSet, args string) ([]string, error) {
+	var positional []string
+	remaining := SplitArgs(args)
+
+	for {
+		if err := flags.Parse(remaining); err != nil {
+			return nil, err
+		}
+
+		if flags.NArg() == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, flags.Arg(0))
+		remaining = flags.Args()[1:]
+	}
+}
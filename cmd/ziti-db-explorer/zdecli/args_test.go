@@ -0,0 +1,88 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"whitespace only", "  \t ", nil},
+		{"words", " a  b\tc ", []string{"a", "b", "c"}},
+		{"double quotes group", `"a b" c`, []string{"a b", "c"}},
+		{"single quotes group", `'a b' c`, []string{"a b", "c"}},
+		{"empty quotes", `"" ''`, []string{"", ""}},
+		{"adjacent quotes join", `a"b c"'d e'`, []string{"ab cd e"}},
+		{"double quotes keep regex escapes", `"\d+" "\bbob\b" "^id\d$"`, []string{`\d+`, `\bbob\b`, `^id\d$`}},
+		{"double quotes unescape quote and backslash", `"a\"b" "c\\d"`, []string{`a"b`, `c\d`}},
+		{"single quotes are literal", `'\d+' 'a\"b' 'c\\d'`, []string{`\d+`, `a\"b`, `c\\d`}},
+		{"single quote in double quotes", `"it's"`, []string{"it's"}},
+		{"double quote in single quotes", `'say "hi"'`, []string{`say "hi"`}},
+		{"unquoted backslash escapes", `a\ b \"c`, []string{"a b", `"c`}},
+		{"trailing backslash kept", `a\`, []string{`a\`}},
+		{"unterminated quote runs to end", `"a b`, []string{"a b"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := SplitArgs(test.args); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("SplitArgs(%q) = %q, want %q", test.args, got, test.want)
+			}
+		})
+	}
+}
+
+func TestTokenizeArgsRaw(t *testing.T) {
+	args := ` name  'a  b' "c\d" `
+	var raw []string
+	for _, token := range tokenizeArgs(args) {
+		raw = append(raw, token.raw(args))
+	}
+
+	want := []string{"name", "'a  b'", `"c\d"`}
+	if !reflect.DeepEqual(raw, want) {
+		t.Errorf("raw tokens = %q, want %q", raw, want)
+	}
+}
+
+func TestSplitCommands(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want []string
+	}{
+		{"single", "ls", []string{"ls"}},
+		{"separated", "cd ziti; ls", []string{"cd ziti", " ls"}},
+		{"double quoted separator", `set a string "x;y"; ls`, []string{`set a string "x;y"`, " ls"}},
+		{"single quoted separator", `query ids 'name = "a;b"'; ls`, []string{`query ids 'name = "a;b"'`, " ls"}},
+		{"escaped separator", `set a string x\;y`, []string{`set a string x\;y`}},
+		{"escaped quote in double quotes", `grep "a\";b"`, []string{`grep "a\";b"`}},
+		{"backslash in single quotes", `grep '\';b`, []string{`grep '\'`, "b"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := SplitCommands(test.line); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("SplitCommands(%q) = %q, want %q", test.line, got, test.want)
+			}
+		})
+	}
+}
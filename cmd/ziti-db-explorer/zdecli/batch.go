@@ -0,0 +1,109 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/openziti/ziti-db-explorer/zdelib"
+	"io"
+	"strings"
+)
+
+// RunBatch executes the commands read from `reader` without a TTY. Commands are separated by new lines or `;`. Blank
+// lines and lines starting with `#` are ignored. Execution stops at the first failing command and an ExitError
+// describing the failure is returned. A transaction left uncommitted at the end of the batch is discarded and
+// reported as a failure.
+func RunBatch(state *zdelib.State, registry *CommandRegistry, reader io.Reader) error {
+	if err := runBatch(state, registry, reader); err != nil {
+		return err
+	}
+
+	if state.InTransaction() {
+		return &ExitError{
+			Code: ExitCommandFailed,
+			Err:  fmt.Errorf("discarded %d uncommitted change(s), end the batch with commit", len(state.Pending())),
+		}
+	}
+
+	return nil
+}
+
+// runBatch executes the commands read from `reader`, see RunBatch.
+func runBatch(state *zdelib.State, registry *CommandRegistry, reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		for _, cmd := range SplitCommands(line) {
+			if err := Execute(state, registry, cmd); err != nil {
+				if err == ErrQuit {
+					return nil
+				}
+				return &ExitError{
+					Code: ExitCommandFailed,
+					Err:  fmt.Errorf("line %d: %s: %w", lineNum, strings.TrimSpace(cmd), err),
+				}
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return &ExitError{Code: ExitCommandFailed, Err: err}
+	}
+
+	return nil
+}
+
+// SplitCommands splits a line of input on `;` characters that are not inside single or double quotes or escaped with
+// a backslash, following the quoting rules of SplitArgs.
+func SplitCommands(line string) []string {
+	var commands []string
+
+	var quote rune
+	escaped := false
+	start := 0
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			}
+		case r == '\\':
+			escaped = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == ';':
+			commands = append(commands, line[start:i])
+			start = i + 1
+		}
+	}
+	commands = append(commands, line[start:])
+
+	return commands
+}
@@ -0,0 +1,58 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRunBatch(t *testing.T) {
+	state := newTestState(t, map[string]string{"a": "1", "b": "2"})
+
+	previous := Stdout
+	defer func() { Stdout = previous }()
+
+	tests := []struct {
+		name   string
+		script string
+		want   int
+		output string
+	}{
+		{"commands", "# comment\n\npwd; count\n", ExitOk, "/keys"},
+		{"quit stops", "pwd; quit; nope", ExitOk, "/keys"},
+		{"failure stops", "pwd\nnope\npwd", ExitCommandFailed, "/keys"},
+		{"quoted separator", `grep ";" -o json`, ExitOk, "[]"},
+	}
+
+	for _, test := range tests {
+		stdout := &bytes.Buffer{}
+		Stdout = stdout
+
+		err := RunBatch(state, NewDefaultRegistry(), strings.NewReader(test.script))
+
+		if got := ExitCode(err); got != test.want {
+			t.Errorf("%s: got exit code %d, want %d: %v", test.name, got, test.want, err)
+		}
+
+		if !strings.Contains(stdout.String(), test.output) {
+			t.Errorf("%s: expected %q in output, got %q", test.name, test.output, stdout)
+		}
+
+		if test.want != ExitOk && !strings.Contains(err.Error(), "line 2: nope") {
+			t.Errorf("%s: expected the failing line in the error, got %v", test.name, err)
+		}
+	}
+}
@@ -19,18 +19,41 @@ import (
 )
 
 var CmdQuit = &Command{"quit", []string{"q"}, "leave this horrible place", nil}
-var CmdList = &Command{"list", []string{"ls"}, "list keys", ListSuggester}
+var CmdList = &Command{"list", []string{"ls"}, "list keys, supports --skip --limit --prefix --match --type --value-match --sort --reverse --from --after --to --before --continue", ListSuggester}
+var CmdTail = &Command{"tail", nil, "list the last keys: tail [n]", nil}
 var CmdListAll = &Command{"list-all", []string{"la"}, "list all keys", nil}
-var CmdCd = &Command{"cd", nil, "enter a bucket", KeySuggester}
+var CmdCd = &Command{"cd", nil, "enter a bucket or path (/ziti/identities, ../services), cd - returns to the previous location", PathSuggester}
 var CmdCount = &Command{"count", nil, "number of keys in bucket", nil}
 var CmdBack = &Command{"back", []string{"b"}, "go back one bucket level (alias b)", nil}
 var CmdRoot = &Command{"root", []string{"r"}, "return to the root node (alias r)", nil}
+var CmdPrev = &Command{"prev", nil, "go to the previously visited location", nil}
+var CmdNext = &Command{"next", nil, "go forward to the next visited location", nil}
+var CmdDirs = &Command{"dirs", nil, "list visited locations", nil}
 var CmdPwd = &Command{"pwd", nil, "print the full path", nil}
 var CmdStatsBucket = &Command{"stats-bucket", nil, "show stats for the current bucket", nil}
 var CmdStatsDb = &Command{"stats-db", nil, "show stats for the db", nil}
 var CmdClear = &Command{"clear", []string{"cls"}, "clear the console", nil}
 var CmdShow = &Command{"show", nil, "print the full value of a key", KeySuggester}
 var CmdHelp = &Command{"help", nil, "prints help", nil}
+var CmdSet = &Command{"set", nil, "set a key to a typed value: set <key> <type> <value> (requires --read-write)", SetSuggester}
+var CmdRm = &Command{"rm", nil, "remove a key (requires --read-write)", KeySuggester}
+var CmdMkBucket = &Command{"mkbucket", nil, "create a bucket (requires --read-write)", nil}
+var CmdRmBucket = &Command{"rmbucket", nil, "remove a bucket, -r if not empty (requires --read-write)", KeySuggester}
+var CmdBegin = &Command{"begin", nil, "stage changes until commit or rollback (requires --read-write)", nil}
+var CmdCommit = &Command{"commit", nil, "apply all staged changes in one transaction", nil}
+var CmdRollback = &Command{"rollback", nil, "discard all staged changes", nil}
+var CmdStatus = &Command{"status", nil, "show staged changes", nil}
+var CmdHistory = &Command{"history", nil, "show previous commands, supports --grep <text> and a count, ctrl-r searches", nil}
+var CmdExport = &Command{"export", nil, "export the current bucket as typed JSON, supports --ndjson --file <path>", ExportSuggester}
+var CmdDescribe = &Command{"describe", nil, "show an entity with lists, tags and referenced names: describe [path|id]", PathSuggester}
+var CmdGoto = &Command{"goto", nil, "go to the entity with an id in any entity type: goto <id>", nil}
+var CmdFollow = &Command{"follow", nil, "go to the entity referenced by a field of the current entity: follow <field> [id]", FollowSuggester}
+var CmdFind = &Command{"find", nil, "find buckets and keys by name: find <glob|/regex/> [--type bucket|key] [--from <path>] [--max-depth <n>]", FindSuggester}
+var CmdGrep = &Command{"grep", nil, "find values matching a regex: grep <regex> [--field <name>] [--type <type>] [-r]", GrepSuggester}
+var CmdQuery = &Command{"query", nil, "find entities with a Ziti filter: query <entity-type> [--fields <field,...>] '<filter>'", QuerySuggester}
+var CmdRefresh = &Command{"refresh", nil, "discard cached bucket listings and counts", nil}
+var CmdSnapshot = &Command{"snapshot", nil, "show or change the pinned snapshot: snapshot [pin|unpin|refresh]", SnapshotSuggester}
+var CmdTree = &Command{"tree", nil, "print nested buckets and keys, supports --depth <n> --buckets-only --counts", TreeSuggester}
 
 // Command represents a string (`Text`) an aliases (`Aliases`) that have a specific description and suggestion
 // result set.
@@ -65,55 +88,308 @@ func (command *Command) Suggest(state *zdelib.State, d prompt.Document) []prompt
 }
 
 const (
-	ArgSkip  = "--skip"
-	ArgLimit = "--limit"
+	ArgSkip   = "--skip"
+	ArgLimit  = "--limit"
+	ArgNdJson = "--ndjson"
+	ArgFile   = "--file"
+
+	ArgDepth       = "--depth"
+	ArgBucketsOnly = "--buckets-only"
+	ArgCounts      = "--counts"
+
+	ArgType     = "--type"
+	ArgFrom     = "--from"
+	ArgMaxDepth = "--max-depth"
+
+	ArgField     = "--field"
+	ArgRecursive = "-r"
+
+	ArgPrefix     = "--prefix"
+	ArgMatch      = "--match"
+	ArgValueMatch = "--value-match"
+	ArgSort       = "--sort"
+	ArgReverse    = "--reverse"
+
+	ArgAfter    = "--after"
+	ArgTo       = "--to"
+	ArgBefore   = "--before"
+	ArgContinue = "--continue"
+
+	ArgFields = "--fields"
 )
 
-func KeySuggester(state *zdelib.State, _ prompt.Document) []prompt.Suggest {
+// KeySuggester returns the keys in the current bucket. Only keys starting with the word before the cursor are read
+// from large buckets, see zdelib.State.CompletionsAt.
+func KeySuggester(state *zdelib.State, d prompt.Document) []prompt.Suggest {
 	var suggestions []prompt.Suggest
-	for _, entry := range state.ListEntries() {
-		suggestions = append(suggestions, prompt.Suggest{Text: entry.Name})
+	for _, name := range state.CompletionsAt(state.Path, d.GetWordBeforeCursor(), false) {
+		suggestions = append(suggestions, prompt.Suggest{Text: name})
+	}
+
+	return suggestions
+}
+
+// PathSuggester returns the buckets within the bucket named by the path before the cursor, so each segment of a
+// path can be completed.
+func PathSuggester(state *zdelib.State, d prompt.Document) []prompt.Suggest {
+	word := d.GetWordBeforeCursor()
+	dir := ""
+	if index := strings.LastIndex(word, zdelib.PathSeparator); index != -1 {
+		dir = word[0 : index+1]
+	}
+
+	path, err := state.Resolve(dir)
+	if err != nil {
+		return nil
+	}
+
+	prefix := strings.TrimPrefix(word[len(dir):], `"`)
+
+	var suggestions []prompt.Suggest
+	for _, name := range state.CompletionsAt(path, prefix, true) {
+		suggestions = append(suggestions, prompt.Suggest{Text: dir + zdelib.QuoteSegment(name) + zdelib.PathSeparator})
 	}
 
 	return suggestions
 }
 
 // ListSuggester returns a list of suggestions for the `list` command
-func ListSuggester(_ *zdelib.State, d prompt.Document) []prompt.Suggest {
+func ListSuggester(state *zdelib.State, d prompt.Document) []prompt.Suggest {
+	switch previousWord(d) {
+	case ArgSkip, ArgLimit:
+		return []prompt.Suggest{{Text: "<n>", Description: "an integer"}}
+	case ArgPrefix:
+		return []prompt.Suggest{{Text: "<prefix>", Description: "the start of the key"}}
+	case ArgMatch, ArgValueMatch:
+		return []prompt.Suggest{{Text: "<regex>", Description: "a regular expression"}}
+	case ArgType:
+		return append(typeSuggestions(), prompt.Suggest{Text: listTypeBucket})
+	case ArgSort:
+		return []prompt.Suggest{{Text: listSortKey}, {Text: listSortType}, {Text: listSortValue}, {Text: listSortSize}}
+	case ArgFrom, ArgAfter, ArgTo, ArgBefore:
+		return KeySuggester(state, d)
+	case ArgContinue:
+		return []prompt.Suggest{{Text: "<token>", Description: "the token printed by the previous list"}}
+	}
+
+	return unusedFlags(d,
+		prompt.Suggest{Text: ArgSkip, Description: ArgSkip + " <n> skips n keys"},
+		prompt.Suggest{Text: ArgLimit, Description: ArgLimit + " <n> limits the results n keys (-1=no limit)"},
+		prompt.Suggest{Text: ArgPrefix, Description: ArgPrefix + " <prefix> only list keys with the prefix"},
+		prompt.Suggest{Text: ArgMatch, Description: ArgMatch + " <regex> only list keys matching the regex"},
+		prompt.Suggest{Text: ArgType, Description: ArgType + " <type|bucket> only list values of the type"},
+		prompt.Suggest{Text: ArgValueMatch, Description: ArgValueMatch + " <regex> only list values matching the regex"},
+		prompt.Suggest{Text: ArgSort, Description: ArgSort + " key|type|value|size sort the results"},
+		prompt.Suggest{Text: ArgReverse, Description: "reverse the order of the results"},
+		prompt.Suggest{Text: ArgFrom, Description: ArgFrom + " <key> start at the key"},
+		prompt.Suggest{Text: ArgAfter, Description: ArgAfter + " <key> start after the key"},
+		prompt.Suggest{Text: ArgTo, Description: ArgTo + " <key> stop at the key"},
+		prompt.Suggest{Text: ArgBefore, Description: ArgBefore + " <key> stop before the key"},
+		prompt.Suggest{Text: ArgContinue, Description: ArgContinue + " <token> continue the previous list"},
+	)
+}
+
+// ExportSuggester returns a list of suggestions for the `export` command
+func ExportSuggester(_ *zdelib.State, d prompt.Document) []prompt.Suggest {
 	var suggestions []prompt.Suggest
 
-	lastSpace1st := strings.LastIndexByte(d.Text, ' ')
-	prevWord := ""
-	if lastSpace1st != -1 {
-		lastSpace2nd := strings.LastIndexByte(d.Text[0:lastSpace1st], ' ')
-		if lastSpace2nd == -1 {
-			lastSpace2nd = 0
+	if !strings.Contains(d.Text, ArgNdJson) {
+		suggestions = append(suggestions, prompt.Suggest{
+			Text:        ArgNdJson,
+			Description: "write one JSON record per line",
+		})
+	}
+
+	if !strings.Contains(d.Text, ArgFile) {
+		suggestions = append(suggestions, prompt.Suggest{
+			Text:        ArgFile,
+			Description: ArgFile + " <path> write to a file instead of the console",
+		})
+	}
+
+	return suggestions
+}
+
+// TreeSuggester returns a list of suggestions for the `tree` command
+func TreeSuggester(_ *zdelib.State, d prompt.Document) []prompt.Suggest {
+	return unusedFlags(d,
+		prompt.Suggest{Text: ArgDepth, Description: ArgDepth + " <n> only descend n bucket levels"},
+		prompt.Suggest{Text: ArgBucketsOnly, Description: "only show buckets"},
+		prompt.Suggest{Text: ArgCounts, Description: "show the number of keys in each bucket"},
+	)
+}
+
+// FindSuggester returns a list of suggestions for the `find` command
+func FindSuggester(state *zdelib.State, d prompt.Document) []prompt.Suggest {
+	switch previousWord(d) {
+	case ArgType:
+		return []prompt.Suggest{
+			{Text: findTypeBucket, Description: "only match buckets"},
+			{Text: findTypeKey, Description: "only match keys that are not buckets"},
 		}
-		prevWord = d.Text[lastSpace2nd:lastSpace1st]
+	case ArgFrom:
+		return PathSuggester(state, d)
+	case ArgMaxDepth:
+		return []prompt.Suggest{{Text: "<n>", Description: "an integer"}}
+	}
+
+	return unusedFlags(d,
+		prompt.Suggest{Text: ArgType, Description: ArgType + " bucket|key only match buckets or keys"},
+		prompt.Suggest{Text: ArgFrom, Description: ArgFrom + " <path> search from a bucket instead of the root"},
+		prompt.Suggest{Text: ArgMaxDepth, Description: ArgMaxDepth + " <n> only descend n bucket levels"},
+	)
+}
+
+// FollowSuggester returns a list of suggestions for the `follow` command. The fields of the current entity that
+// reference other entities are suggested for the first argument and the referenced ids for the second.
+func FollowSuggester(state *zdelib.State, d prompt.Document) []prompt.Suggest {
+	if state.AtRoot() {
+		return nil
+	}
+
+	args := strings.Fields(d.TextBeforeCursor())
+	argIndex := len(args) - 1
+	if strings.HasSuffix(d.TextBeforeCursor(), " ") {
+		argIndex++
 	}
 
-	if prevWord == " --skip" || prevWord == " --limit" {
-		suggestions = []prompt.Suggest{
-			{
-				Text:        "<n>",
-				Description: "an integer",
-			},
+	entity, err := state.DescribeEntity(state.Path)
+	if err != nil {
+		return nil
+	}
+
+	var suggestions []prompt.Suggest
+	for _, field := range entity.Fields {
+		if len(field.References) == 0 {
+			continue
 		}
-	} else {
-		if !strings.Contains(d.Text, ArgSkip) {
-			suggestions = append(suggestions, prompt.Suggest{
-				Text:        ArgSkip,
-				Description: ArgSkip + " <n> skips n keys",
-			})
+
+		switch {
+		case argIndex == 1:
+			suggestions = append(suggestions, prompt.Suggest{Text: field.Name, Description: strings.Join(describeReferences(field), ", ")})
+		case argIndex == 2 && field.Name == args[1]:
+			for _, id := range field.ReferencedIds() {
+				reference := field.References[id]
+				suggestions = append(suggestions, prompt.Suggest{Text: id, Description: strings.TrimSpace(reference.Name + " (" + reference.Type + ")")})
+			}
 		}
+	}
 
-		if !strings.Contains(d.Text, ArgLimit) {
-			suggestions = append(suggestions, prompt.Suggest{
-				Text:        ArgLimit,
-				Description: ArgLimit + " <n> limits the results n keys (-1=no limit)",
-			})
+	return suggestions
+}
+
+// QuerySuggester returns a list of suggestions for the `query` command. Entity types are suggested for the first
+// argument.
+func QuerySuggester(state *zdelib.State, d prompt.Document) []prompt.Suggest {
+	args := strings.Fields(d.TextBeforeCursor())
+	argIndex := len(args) - 1
+	if strings.HasSuffix(d.TextBeforeCursor(), " ") {
+		argIndex++
+	}
+
+	if argIndex == 1 {
+		var suggestions []prompt.Suggest
+		for _, entityType := range state.EntityTypes() {
+			suggestions = append(suggestions, prompt.Suggest{Text: entityType})
+		}
+		return suggestions
+	}
+
+	if argIndex == 2 {
+		return unusedFlags(d, prompt.Suggest{Text: ArgFields, Description: ArgFields + " <field,...> fields to show instead of those in the filter"})
+	}
+
+	return nil
+}
+
+// GrepSuggester returns a list of suggestions for the `grep` command
+func GrepSuggester(state *zdelib.State, d prompt.Document) []prompt.Suggest {
+	switch previousWord(d) {
+	case ArgField:
+		return KeySuggester(state, d)
+	case ArgType:
+		return typeSuggestions()
+	}
+
+	return unusedFlags(d,
+		prompt.Suggest{Text: ArgField, Description: ArgField + " <name> only match keys with this name"},
+		prompt.Suggest{Text: ArgType, Description: ArgType + " <type> only match values of this type"},
+		prompt.Suggest{Text: ArgRecursive, Description: "search nested buckets"},
+	)
+}
+
+// SnapshotSuggester returns a list of suggestions for the `snapshot` command
+func SnapshotSuggester(_ *zdelib.State, d prompt.Document) []prompt.Suggest {
+	if len(strings.Fields(d.TextBeforeCursor())) > 2 {
+		return nil
+	}
+
+	return []prompt.Suggest{
+		{Text: snapshotPin, Description: "read every command from the current snapshot"},
+		{Text: snapshotUnpin, Description: "read every command from the latest data"},
+		{Text: snapshotRefresh, Description: "move the pinned snapshot to the latest data"},
+	}
+}
+
+// previousWord returns the complete word before the one being typed.
+func previousWord(d prompt.Document) string {
+	words := strings.Fields(d.TextBeforeCursor())
+
+	if d.GetWordBeforeCursor() != "" {
+		words = words[0 : len(words)-1]
+	}
+
+	if len(words) == 0 {
+		return ""
+	}
+
+	return words[len(words)-1]
+}
+
+// unusedFlags returns the flag suggestions that do not already appear in the document's text.
+func unusedFlags(d prompt.Document, flags ...prompt.Suggest) []prompt.Suggest {
+	var suggestions []prompt.Suggest
+
+	words := strings.Fields(d.Text)
+
+	for _, flag := range flags {
+		used := false
+		for _, word := range words {
+			used = used || word == flag.Text
+		}
+
+		if !used {
+			suggestions = append(suggestions, flag)
 		}
 	}
 
 	return suggestions
 }
+
+// SetSuggester returns a list of suggestions for the `set` command. Keys are suggested for the first argument and
+// types for the second.
+func SetSuggester(state *zdelib.State, d prompt.Document) []prompt.Suggest {
+	args := strings.Fields(d.TextBeforeCursor())
+	argIndex := len(args) - 1
+	if strings.HasSuffix(d.TextBeforeCursor(), " ") {
+		argIndex++
+	}
+
+	switch argIndex {
+	case 1:
+		return KeySuggester(state, d)
+	case 2:
+		return typeSuggestions()
+	}
+
+	return nil
+}
+
+// typeSuggestions returns the value type names accepted by zdelib.ParseType.
+func typeSuggestions() []prompt.Suggest {
+	var suggestions []prompt.Suggest
+	for _, typeName := range []string{"string", "int32", "int64", "float64", "bool", "time", "nil"} {
+		suggestions = append(suggestions, prompt.Suggest{Text: typeName})
+	}
+	return suggestions
+}
@@ -0,0 +1,123 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"errors"
+	"fmt"
+	"github.com/openziti/ziti-db-explorer/zdelib"
+	"strings"
+)
+
+// DescribeEntity is an ActionHandler that prints an entity as a record of its fields. Lists such as role attributes
+// are shown as arrays, maps such as tags as objects and ids of other entities are resolved to their names. The
+// argument may be a path, an id in the current bucket or the id of any entity, the current bucket is described if it
+// is omitted.
+func DescribeEntity(state *zdelib.State, _ *CommandRegistry, args string) error {
+	path, err := resolveEntity(state, strings.TrimSpace(args))
+
+	if err != nil {
+		return err
+	}
+
+	entity, err := state.DescribeEntity(path)
+
+	if err != nil {
+		return err
+	}
+
+	result := NewResult("field", "type", "value", "references")
+	result.MaxCellWidth = 120
+	result.Footer = []string{fmt.Sprintf("%s: %s", entity.Type, entity.Path)}
+
+	for _, field := range entity.Fields {
+		var references interface{} = Placeholder("")
+		if len(field.References) > 0 {
+			references = describeReferences(field)
+		}
+		result.AddRow(field.Name, field.Type, field.Value, references)
+	}
+
+	return Render(result)
+}
+
+// resolveEntity returns the path of the entity named by `target`: the current bucket if it is empty, a bucket if it
+// is a path to one and otherwise the entity with that id found by zdelib.State.FindEntity.
+func resolveEntity(state *zdelib.State, target string) (zdelib.Path, error) {
+	if target == "" {
+		return state.Path, nil
+	}
+
+	path, err := state.Resolve(target)
+
+	if err == nil {
+		return path, nil
+	}
+
+	path, findErr := state.FindEntity(target)
+
+	if findErr == nil {
+		return path, nil
+	}
+
+	// report the id lookup for a plain name, as that is what was likely meant
+	if !strings.Contains(target, zdelib.PathSeparator) {
+		return nil, findErr
+	}
+
+	return nil, err
+}
+
+// describeReferences lists the entities referenced by `field` in id order as `<id>: <name> (<type>)`.
+func describeReferences(field *zdelib.EntityField) []string {
+	var result []string
+	for _, id := range field.ReferencedIds() {
+		reference := field.References[id]
+		if reference.Name != "" {
+			result = append(result, fmt.Sprintf("%s: %s (%s)", id, reference.Name, reference.Type))
+		} else {
+			result = append(result, fmt.Sprintf("%s: (%s)", id, reference.Type))
+		}
+	}
+
+	return result
+}
+
+// GotoEntity is an ActionHandler that moves to the entity with the provided id, searching every entity type.
+func GotoEntity(state *zdelib.State, _ *CommandRegistry, args string) error {
+	positional := SplitArgs(args)
+
+	if len(positional) != 1 {
+		return errors.New("usage: goto <id>")
+	}
+
+	return state.EnterEntity(positional[0])
+}
+
+// FollowReference is an ActionHandler that moves to the entity referenced by a field of the current entity. Fields
+// referencing several entities, such as links, take the id to follow as a second argument.
+func FollowReference(state *zdelib.State, _ *CommandRegistry, args string) error {
+	positional := SplitArgs(args)
+
+	if len(positional) < 1 || len(positional) > 2 {
+		return errors.New("usage: follow <field> [id]")
+	}
+
+	id := ""
+	if len(positional) == 2 {
+		id = positional[1]
+	}
+
+	return state.FollowReference(positional[0], id)
+}
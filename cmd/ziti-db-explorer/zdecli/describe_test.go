@@ -0,0 +1,144 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"encoding/json"
+	"github.com/openziti/ziti-db-explorer/zdelib"
+	"go.etcd.io/bbolt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newEntityTestState returns a State for a db holding an identity referencing an auth policy, and an id used by two
+// entity types.
+func newEntityTestState(t *testing.T) *zdelib.State {
+	return newTestStateWith(t, func(tx *bbolt.Tx) error {
+		entities := map[string]map[string]interface{}{
+			"identities/id0":   {"name": "alice", "authPolicyId": "ap0", "count": int64(1)},
+			"authPolicies/ap0": {"name": "default"},
+			"configs/dup":      {"name": "first"},
+			"routers/dup":      {"name": "second"},
+		}
+
+		for path, values := range entities {
+			if err := putValues(tx, append(zdelib.Path{"ziti"}, strings.Split(path, "/")...), values); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// describedReferences returns the references of each field in the JSON output of describe, keyed by field name.
+func describedReferences(t *testing.T, output string) map[string]interface{} {
+	t.Helper()
+
+	var rows []map[string]interface{}
+	if err := json.Unmarshal([]byte(output), &rows); err != nil {
+		t.Fatalf("invalid json output %q: %v", output, err)
+	}
+
+	result := map[string]interface{}{}
+	for _, row := range rows {
+		result[row["field"].(string)] = row["references"]
+	}
+
+	return result
+}
+
+func TestDescribeEntity(t *testing.T) {
+	state := newEntityTestState(t)
+
+	want := map[string]interface{}{
+		"authPolicyId": []interface{}{"ap0: default (authPolicies)"},
+		"count":        nil,
+		"name":         nil,
+	}
+
+	tests := []struct {
+		current string
+		target  string
+	}{
+		{"/", "id0"},
+		{"/", "/ziti/identities/id0"},
+		{"/ziti", "identities/id0"},
+		{"/ziti/identities/id0", ""},
+	}
+
+	for _, test := range tests {
+		execute(t, state, "cd "+test.current)
+		stdout, _ := execute(t, state, "describe "+test.target+" -o json")
+
+		if got := describedReferences(t, stdout); !reflect.DeepEqual(got, want) {
+			t.Errorf("describe %q in %s: got %v, want %v", test.target, test.current, got, want)
+		}
+	}
+}
+
+func TestDescribeEntityErrors(t *testing.T) {
+	state := newEntityTestState(t)
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"missing", "no entity with id missing"},
+		{"dup", "id dup is ambiguous, use one of the paths: /ziti/configs/dup, /ziti/routers/dup"},
+		{"/ziti/identities/missing", "missing"},
+		{"/", "the root bucket is not an entity"},
+	}
+
+	for _, test := range tests {
+		err := Execute(state, NewDefaultRegistry(), "describe "+test.target)
+
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("describe %s: expected an error containing %q, got %v", test.target, test.want, err)
+		}
+	}
+}
+
+func TestGotoAndFollow(t *testing.T) {
+	state := newEntityTestState(t)
+
+	steps := []struct {
+		input string
+		fails bool
+		want  string
+	}{
+		{"goto id0", false, "/ziti/identities/id0"},
+		{"follow authPolicyId", false, "/ziti/authPolicies/ap0"},
+		{"cd -", false, "/ziti/identities/id0"},
+		{"cd -", false, "/ziti/authPolicies/ap0"},
+		{"follow name", true, "/ziti/authPolicies/ap0"},
+		{"goto dup", true, "/ziti/authPolicies/ap0"},
+		{"goto missing", true, "/ziti/authPolicies/ap0"},
+		{"goto", true, "/ziti/authPolicies/ap0"},
+		{"follow", true, "/ziti/authPolicies/ap0"},
+	}
+
+	for _, step := range steps {
+		err := Execute(state, NewDefaultRegistry(), step.input)
+
+		if step.fails != (err != nil) {
+			t.Errorf("%s: expected failure %v, got %v", step.input, step.fails, err)
+		}
+
+		if got := state.Path.String(); got != step.want {
+			t.Errorf("%s: got %s, want %s", step.input, got, step.want)
+		}
+	}
+}
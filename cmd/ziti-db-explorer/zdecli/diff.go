@@ -0,0 +1,117 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/openziti/ziti-db-explorer/zdelib"
+	"io"
+)
+
+// RunDiff implements the `diff <old.db> <new.db>` sub command. It prints every bucket and key that was added, removed
+// or changed between the two files. If --path is supplied only that bucket is compared. An ExitError with
+// ExitCommandFailed and no message is returned if differences are found, mirroring diff(1). A malformed --path is a
+// usage error, failures to compare, such as a path that is in neither file, are returned with ExitCommandFailed and a
+// message.
+func RunDiff(options *Options) error {
+	if len(options.Args) != 3 {
+		return usageError("diff requires two db files")
+	}
+
+	path, err := zdelib.ParsePath(options.Path)
+	if err != nil {
+		return usageError(fmt.Sprintf("invalid --path: %v", err))
+	}
+
+	openOptions := &zdelib.OpenOptions{
+		Timeout:  options.Timeout,
+		ReadOnly: true,
+	}
+
+	oldDb, err := zdelib.OpenWithOptions(options.Args[1], openOptions)
+	if err != nil {
+		return &ExitError{Code: ExitOpenFailed, Err: fmt.Errorf("%s: %w", options.Args[1], err)}
+	}
+	defer func() { _ = oldDb.Close() }()
+
+	newDb, err := zdelib.OpenWithOptions(options.Args[2], openOptions)
+	if err != nil {
+		return &ExitError{Code: ExitOpenFailed, Err: fmt.Errorf("%s: %w", options.Args[2], err)}
+	}
+	defer func() { _ = newDb.Close() }()
+
+	result := NewResult("change", "path", "key", "oldType", "oldValue", "newType", "newValue")
+
+	var differences []*zdelib.Difference
+	err = zdelib.Diff(oldDb, newDb, path, func(difference *zdelib.Difference) error {
+		differences = append(differences, difference)
+
+		oldType, oldValue := diffEntryColumns(difference.Old)
+		newType, newValue := diffEntryColumns(difference.New)
+
+		result.AddRow(string(difference.Kind), difference.Path.String(), difference.Key, oldType, oldValue, newType, newValue)
+		return nil
+	})
+
+	if err != nil {
+		return &ExitError{Code: ExitCommandFailed, Err: err}
+	}
+
+	result.Text = func(out io.Writer) {
+		for i, difference := range differences {
+			printDifference(out, difference, result.Rows[i])
+		}
+	}
+
+	if err := Render(result); err != nil {
+		return err
+	}
+
+	if len(differences) > 0 {
+		return &ExitError{Code: ExitCommandFailed}
+	}
+
+	return nil
+}
+
+// diffEntryColumns returns the type and value to display for one side of a difference.
+func diffEntryColumns(entry *zdelib.Entry) (interface{}, interface{}) {
+	if entry == nil {
+		return nil, nil
+	}
+
+	return entryTypeString(entry), entryValue(entry)
+}
+
+// printDifference prints a single difference as a line prefixed with +, - or ~.
+func printDifference(out io.Writer, difference *zdelib.Difference, row []interface{}) {
+	fullPath := difference.Path.Child(difference.Key)
+
+	describe := func(entryType, entryValue interface{}) string {
+		if entryType == "Bucket" {
+			return "(Bucket)"
+		}
+		return fmt.Sprintf("(%v) %s", entryType, tableCell(entryValue, 80))
+	}
+
+	switch difference.Kind {
+	case zdelib.DiffAdded:
+		_, _ = fmt.Fprintln(out, color.GreenString("+ %s %s", fullPath, describe(row[5], row[6])))
+	case zdelib.DiffRemoved:
+		_, _ = fmt.Fprintln(out, color.RedString("- %s %s", fullPath, describe(row[3], row[4])))
+	case zdelib.DiffChanged:
+		_, _ = fmt.Fprintln(out, color.YellowString("~ %s %s -> %s", fullPath, describe(row[3], row[4]), describe(row[5], row[6])))
+	}
+}
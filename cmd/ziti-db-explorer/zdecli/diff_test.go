@@ -0,0 +1,52 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunDiffExitCodes(t *testing.T) {
+	state := newTestState(t, map[string]string{"a": "1"})
+	dbFile := state.DB.Path()
+
+	previous := Stdout
+	Stdout = &bytes.Buffer{}
+	defer func() { Stdout = previous }()
+
+	tests := []struct {
+		name string
+		args []string
+		path string
+		want int
+	}{
+		{"same file", []string{"diff", dbFile, dbFile}, "", ExitOk},
+		{"missing path", []string{"diff", dbFile, dbFile}, "/missing", ExitCommandFailed},
+		{"invalid path", []string{"diff", dbFile, dbFile}, `/"keys`, ExitUsage},
+		{"invalid path before missing file", []string{"diff", dbFile, filepath.Join(t.TempDir(), "missing.db")}, `/"keys`, ExitUsage},
+		{"missing file", []string{"diff", dbFile, filepath.Join(t.TempDir(), "missing.db")}, "", ExitOpenFailed},
+		{"missing argument", []string{"diff", dbFile}, "", ExitUsage},
+	}
+
+	for _, test := range tests {
+		err := RunDiff(&Options{Args: test.args, Path: test.path, Timeout: time.Second})
+
+		if got := ExitCode(err); got != test.want {
+			t.Errorf("%s: got exit code %d, want %d: %v", test.name, got, test.want, err)
+		}
+	}
+}
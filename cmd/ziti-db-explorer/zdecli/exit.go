@@ -0,0 +1,64 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"errors"
+)
+
+// Process exit codes reported for errors returned from Run and RunArgs.
+const (
+	ExitOk            = 0
+	ExitCommandFailed = 1
+	ExitUsage         = 2
+	ExitOpenFailed    = 3
+)
+
+// ExitError associates an error with the process exit code that should be reported for it. Err may be nil when only
+// the exit code needs to be reported.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return ""
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the process exit code for an error returned from Run or RunArgs. Errors that do not carry an
+// exit code are reported as ExitCommandFailed.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOk
+	}
+
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+
+	return ExitCommandFailed
+}
+
+// usageError returns an ExitError for invalid command line usage.
+func usageError(msg string) error {
+	return &ExitError{Code: ExitUsage, Err: errors.New(msg)}
+}
@@ -0,0 +1,67 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"errors"
+	"github.com/openziti/ziti-db-explorer/zdelib"
+	"os"
+)
+
+// ExportCurrentBucket is an ActionHandler that writes the provided `state`'s current bucket and all nested buckets as
+// a typed JSON document. Supports `--ndjson` to write one record per line and `--file <path>` to write to a file
+// instead of Stdout.
+func ExportCurrentBucket(state *zdelib.State, _ *CommandRegistry, args string) error {
+	flags := newCommandFlags(CmdExport)
+	ndjson := flags.Bool("ndjson", false, "write one JSON record per line")
+	file := flags.String("file", "", "write to a file instead of stdout")
+
+	positional, err := parseCommandFlags(flags, args)
+
+	if err != nil {
+		return err
+	}
+
+	if len(positional) > 0 {
+		return errors.New("export takes no arguments, cd to the bucket to export")
+	}
+
+	format := zdelib.ExportJson
+	if *ndjson || OutputFormat == FormatNdJson {
+		format = zdelib.ExportNdJson
+	}
+
+	if *file == "" {
+		return state.Export(Stdout, format)
+	}
+
+	outFile, err := os.Create(*file)
+	if err != nil {
+		return err
+	}
+
+	if err := state.Export(outFile, format); err != nil {
+		_ = outFile.Close()
+		return err
+	}
+
+	// buffered data is only known to be written once the file is closed
+	if err := outFile.Close(); err != nil {
+		return err
+	}
+
+	logInfo("exported to %s", *file)
+
+	return nil
+}
@@ -0,0 +1,207 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"errors"
+	"fmt"
+	"github.com/openziti/ziti-db-explorer/zdelib"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const (
+	findTypeBucket = "bucket"
+	findTypeKey    = "key"
+)
+
+// FindKeys is an ActionHandler that prints the full path of every bucket and key whose name matches a pattern.
+// Patterns wrapped in slashes, e.g. `/^id[0-9]+$/`, are regular expressions and anything else is a glob where `*`
+// and `?` are wildcards. The whole database is searched unless `--from <path>` is supplied. Supports
+// `--type bucket|key` and `--max-depth <n>`.
+func FindKeys(state *zdelib.State, _ *CommandRegistry, args string) error {
+	flags := newCommandFlags(CmdFind)
+	findType := flags.String("type", "", "only match buckets or keys")
+	from := flags.String("from", zdelib.PathSeparator, "path to search from")
+	maxDepth := flags.Int("max-depth", 0, "bucket levels to descend, 0 for no limit")
+
+	positional, err := parseCommandFlags(flags, args)
+
+	if err != nil {
+		return err
+	}
+
+	if len(positional) != 1 {
+		return errors.New("usage: find <glob|/regex/> [--type bucket|key] [--from <path>] [--max-depth <n>]")
+	}
+
+	if *findType != "" && *findType != findTypeBucket && *findType != findTypeKey {
+		return fmt.Errorf("invalid type %s, must be %s or %s", *findType, findTypeBucket, findTypeKey)
+	}
+
+	if *maxDepth < 0 {
+		return errors.New("max-depth must not be negative")
+	}
+
+	matches, err := compilePattern(positional[0])
+
+	if err != nil {
+		return err
+	}
+
+	fromPath, err := state.Resolve(*from)
+
+	if err != nil {
+		return err
+	}
+
+	options := &zdelib.WalkOptions{
+		MaxDepth:    *maxDepth,
+		BucketsOnly: *findType == findTypeBucket,
+	}
+
+	result := NewResult("path", "type")
+
+	err = state.Walk(fromPath, options, func(entry *zdelib.WalkEntry) error {
+		if *findType == findTypeKey && entry.Entry.IsBucket {
+			return nil
+		}
+
+		if matches(entry.Entry.Name) {
+			result.AddRow(entry.Path.Child(entry.Entry.Name).String(), entryTypeString(&entry.Entry))
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	result.Text = func(out io.Writer) {
+		for _, row := range result.Rows {
+			_, _ = fmt.Fprintln(out, row[0])
+		}
+	}
+
+	return Render(result)
+}
+
+// compilePattern returns a function matching names against `pattern`. Patterns wrapped in slashes are regular
+// expressions, anything else is a glob, see globToRegexp.
+func compilePattern(pattern string) (func(string) bool, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		expr, err := regexp.Compile(pattern[1 : len(pattern)-1])
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %w", err)
+		}
+
+		return expr.MatchString, nil
+	}
+
+	expr, err := globToRegexp(pattern)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob %s: %w", pattern, err)
+	}
+
+	return expr.MatchString, nil
+}
+
+// globToRegexp converts a glob with the syntax of path.Match into a regular expression matching a whole name. As a
+// glob is matched against a single bucket or key name, which may itself contain `/`, `*` and `?` match any character
+// including `/`.
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	expr := strings.Builder{}
+	expr.WriteString(`(?s)^`)
+
+	runes := []rune(glob)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '*':
+			expr.WriteString(`.*`)
+		case '?':
+			expr.WriteString(`.`)
+		case '\\':
+			i++
+			if i >= len(runes) {
+				return nil, errors.New("trailing backslash")
+			}
+			expr.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case '[':
+			end, class, err := globClass(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			expr.WriteString(class)
+			i = end
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	expr.WriteString(`$`)
+
+	return regexp.Compile(expr.String())
+}
+
+// globClass converts the character class starting at `runes[start]` into a regular expression class. The index of
+// the closing `]` is returned along with the class.
+func globClass(runes []rune, start int) (int, string, error) {
+	class := strings.Builder{}
+	class.WriteString("[")
+
+	i := start + 1
+	if i < len(runes) && (runes[i] == '^' || runes[i] == '!') {
+		class.WriteString("^")
+		i++
+	}
+
+	for first := true; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r == ']' && !first:
+			class.WriteString("]")
+			return i, class.String(), nil
+		case r == '\\':
+			i++
+			if i >= len(runes) {
+				return 0, "", errors.New("trailing backslash")
+			}
+			class.WriteString(classLiteral(runes[i]))
+		case r == '-' && !first && i+1 < len(runes) && runes[i+1] != ']':
+			class.WriteString("-")
+		default:
+			if r == ']' {
+				return 0, "", errors.New("empty character class")
+			}
+			class.WriteString(classLiteral(r))
+		}
+
+		first = false
+	}
+
+	return 0, "", errors.New("unterminated character class")
+}
+
+// classLiteral returns `r` escaped for use inside a regular expression character class.
+func classLiteral(r rune) string {
+	if strings.ContainsRune(`\[]^-`, r) {
+		return `\` + string(r)
+	}
+	return string(r)
+}
@@ -0,0 +1,68 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"testing"
+)
+
+func TestCompilePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"id*", "id0", true},
+		{"id*", "xid0", false},
+		{"*", "a/b", true},
+		{"a*b", "a/x/b", true},
+		{"a?b", "a/b", true},
+		{"a?b", "ab", false},
+		{"*.json", "config.json", true},
+		{"*.json", "configxjson", false},
+		{"id[0-9]", "id7", true},
+		{"id[0-9]", "idx", false},
+		{"id[^0-9]", "idx", true},
+		{"id[!0-9]", "id7", false},
+		{"[a-]", "-", true},
+		{`\*`, "*", true},
+		{`\*`, "a", false},
+		{`[\]]`, "]", true},
+		{"(a)+", "(a)+", true},
+		{"(a)+", "aa", false},
+		{`/^id\d+$/`, "id12", true},
+		{`/^id\d+$/`, "idx", false},
+		{`/a\/b/`, "a/b", true},
+	}
+
+	for _, test := range tests {
+		matches, err := compilePattern(test.pattern)
+		if err != nil {
+			t.Errorf("%s: %v", test.pattern, err)
+			continue
+		}
+
+		if got := matches(test.name); got != test.want {
+			t.Errorf("%s matching %s: got %v, want %v", test.pattern, test.name, got, test.want)
+		}
+	}
+}
+
+func TestCompilePatternErrors(t *testing.T) {
+	for _, pattern := range []string{`a\`, "[a", "[]", "[^]", `/a(/`} {
+		if _, err := compilePattern(pattern); err == nil {
+			t.Errorf("expected an error compiling %s", pattern)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/openziti/storage/boltz"
+	"github.com/openziti/ziti-db-explorer/zdelib"
+	"io"
+	"regexp"
+)
+
+// GrepValues is an ActionHandler that prints every value in the provided `state`'s current bucket whose decoded
+// string form matches a regular expression. Items of string list buckets are matched by the item itself. Supports
+// `--field <name>` to only match keys with that name, `--type <type>` to only match values of that type and `-r` to
+// search nested buckets as well.
+func GrepValues(state *zdelib.State, _ *CommandRegistry, args string) error {
+	flags := newCommandFlags(CmdGrep)
+	field := flags.String("field", "", "only match keys with this name")
+	typeName := flags.String("type", "", "only match values of this type")
+	recursive := flags.Bool("r", false, "search nested buckets")
+
+	positional, err := parseCommandFlags(flags, args)
+
+	if err != nil {
+		return err
+	}
+
+	if len(positional) != 1 {
+		return errors.New("usage: grep <regex> [--field <name>] [--type <type>] [-r]")
+	}
+
+	expr, err := regexp.Compile(positional[0])
+
+	if err != nil {
+		return fmt.Errorf("invalid regular expression: %w", err)
+	}
+
+	var fieldType *boltz.FieldType
+	if *typeName != "" {
+		parsedType, err := zdelib.ParseType(*typeName)
+		if err != nil {
+			return err
+		}
+		fieldType = &parsedType
+	}
+
+	options := &zdelib.WalkOptions{}
+	if !*recursive {
+		options.MaxDepth = 1
+	}
+
+	result := NewResult("path", "key", "type", "value")
+	var keyPaths []string
+
+	err = state.Walk(state.Path, options, func(entry *zdelib.WalkEntry) error {
+		if entry.Entry.IsBucket || entry.Entry.ValueString == nil {
+			return nil
+		}
+
+		if fieldType != nil && entry.Entry.Type != *fieldType {
+			return nil
+		}
+
+		name := entry.Entry.Name
+		value := *entry.Entry.ValueString
+
+		if entry.Entry.IsListItem {
+			value = name
+			if len(entry.Path) > 0 {
+				name = entry.Path[len(entry.Path)-1]
+			}
+		}
+
+		if *field != "" && name != *field {
+			return nil
+		}
+
+		if expr.MatchString(value) {
+			result.AddRow(entry.Path.String(), entry.Entry.Name, entry.Entry.TypeString, value)
+			keyPaths = append(keyPaths, entry.Path.Child(entry.Entry.Name).String())
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	result.Text = func(out io.Writer) {
+		for i, row := range result.Rows {
+			value := expr.ReplaceAllStringFunc(tableCell(row[3], 0), func(match string) string {
+				return color.RedString(match)
+			})
+
+			_, _ = fmt.Fprintf(out, "%s: (%s) %s\n", keyPaths[i], row[2], value)
+		}
+	}
+
+	return Render(result)
+}
@@ -0,0 +1,81 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGrep(t *testing.T) {
+	state := newTestState(t, map[string]string{"a": "bob 123", "b": "alice", "c": "bobby 7", "d": "a.b"})
+
+	tests := []struct {
+		args string
+		want []string
+	}{
+		{`bob`, []string{"a", "c"}},
+		{`^bob$`, nil},
+		{`[0-9]+$`, []string{"a", "c"}},
+		{`^a.b$`, []string{"d"}},
+		{`^bob --field c`, []string{"c"}},
+		{`^bob --field b`, nil},
+		{`bob --type string`, []string{"a", "c"}},
+		{`bob --type int64`, nil},
+		{`alice -r`, []string{"b"}},
+	}
+
+	for _, test := range tests {
+		stdout, _ := execute(t, state, "grep "+test.args+" -o json")
+
+		if got := jsonKeys(t, stdout); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("grep %s: got %v, want %v", test.args, got, test.want)
+		}
+	}
+}
+
+func TestGrepInvalidPattern(t *testing.T) {
+	state := newTestState(t, map[string]string{"a": "bob"})
+
+	if err := Execute(state, NewDefaultRegistry(), "grep (bob"); err == nil {
+		t.Error("expected an error for an invalid regular expression")
+	}
+}
+
+func TestGrepEscapedPatterns(t *testing.T) {
+	state := newTestState(t, map[string]string{"a": "bob 123", "b": "alice", "c": "bobby 7", "d": "a.b"})
+
+	tests := []struct {
+		args string
+		want []string
+	}{
+		{`"\d+"`, []string{"a", "c"}},
+		{`'\d+'`, []string{"a", "c"}},
+		{`"\bbob\b"`, []string{"a"}},
+		{`"\s7$"`, []string{"c"}},
+		{`"^a\.b$"`, []string{"d"}},
+		{`"^a\.b$" --field d`, []string{"d"}},
+		{`"^a\.b$" --field a`, nil},
+		{`"\d+" --type int64`, nil},
+		{`"\bbob\b" -r`, []string{"a"}},
+	}
+
+	for _, test := range tests {
+		stdout, _ := execute(t, state, "grep "+test.args+" -o json")
+
+		if got := jsonKeys(t, stdout); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("grep %s: got %v, want %v", test.args, got, test.want)
+		}
+	}
+}
@@ -13,21 +13,31 @@
 package zdecli
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
-	"github.com/fatih/color"
 	"github.com/openziti/storage/boltz"
 	"github.com/openziti/ziti-db-explorer/zdelib"
-	"github.com/rodaine/table"
+	"io"
+	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func PrintUsage() {
 	println("")
 	fmt.Printf("'%s' is an interactive shell for exploring Ziti Controller database files\n", CommandName)
 	println("\nUsage: ")
-	fmt.Printf("\t%s [help|version|<ctrl.db>]\n", CommandName)
+	fmt.Printf("\t%s [help|version]\n", CommandName)
+	fmt.Printf("\t%s <ctrl.db> [flags]\n", CommandName)
+	fmt.Printf("\t%s <ctrl.db> [flags] < <script>\n", CommandName)
+	fmt.Printf("\t%s import <export.json|-> <new.db> [--force]\n", CommandName)
+	fmt.Printf("\t%s diff <old.db> <new.db> [--path <path>]\n", CommandName)
+	println("\nFlags: ")
+	PrintFlags(os.Stdout)
 	println("")
 }
 
@@ -41,18 +51,17 @@ func PrintVersion() {
 }
 
 func PrintHelp(_ *zdelib.State, registry *CommandRegistry, _ string) error {
-	tbl := table.New("command", "description")
+	result := NewResult("command", "description")
 	for _, cmdText := range registry.CommandTexts {
 		action := registry.CommandTextToAction[cmdText]
 
 		if action.IsSuggested {
-			tbl.AddRow(action.Text, action.Description)
+			result.AddRow(action.Text, action.Description)
 		}
 
 	}
 
-	tbl.Print()
-	return nil
+	return Render(result)
 }
 
 // PrintValue will attempt to print the value from a given `key` in the current bucket location determined by
@@ -60,9 +69,20 @@ func PrintHelp(_ *zdelib.State, registry *CommandRegistry, _ string) error {
 func PrintValue(state *zdelib.State, _ *CommandRegistry, key string) error {
 	key = strings.TrimSpace(key)
 
-	println(state.GetValue(key))
+	entry := state.GetEntry(key)
 
-	return nil
+	if entry == nil {
+		return fmt.Errorf("key not found: %s", key)
+	}
+
+	result := NewResult("key", "type", "value")
+	result.Text = func(out io.Writer) {
+		_, _ = fmt.Fprintln(out, textValue(entryValue(entry)))
+	}
+
+	result.AddRow(entry.Name, entryTypeString(entry), entryValue(entry))
+
+	return Render(result)
 }
 
 // ClearConsole will output ASCII control characters that clear the console on modern terminals.
@@ -71,36 +91,35 @@ func ClearConsole(_ *zdelib.State, _ *CommandRegistry, _ string) error {
 	return nil
 }
 
-// CdBucket is an ActionHandler to `cd <bucket`. Will update the provided `state`'s location.
-func CdBucket(state *zdelib.State, registry *CommandRegistry, bucketName string) error {
-	bucketName = strings.TrimSpace(bucketName)
+// CdBucket is an ActionHandler to `cd <path>`. Will update the provided `state`'s location. Paths may contain
+// multiple buckets separated by `/`, start with `/` to begin at the root and use `..` to move up a level.
+func CdBucket(state *zdelib.State, _ *CommandRegistry, path string) error {
+	path = strings.TrimSpace(path)
 
-	if bucketName == ".." {
-		return NavBackOne(state, registry, bucketName)
+	if path == "-" {
+		return state.Toggle()
 	}
-	return state.Enter(bucketName)
+
+	return state.EnterPath(path)
 }
 
 // PrintCurrentCount is an ActionHandler that will print the key count for the provided `state`'s location.
 func PrintCurrentCount(state *zdelib.State, _ *CommandRegistry, _ string) error {
 	count := state.CurrentBucketKeyCount()
 
-	println("")
-	fmt.Printf("Count: %d\n", count)
-	println("")
+	result := NewResult("count").AddRow(count)
+	result.Text = func(out io.Writer) {
+		_, _ = fmt.Fprintf(out, "\nCount: %d\n\n", count)
+	}
 
-	return nil
+	return Render(result)
 }
 
 // PrintDbStats is an ActionHandler that will print the bbolt database stats the current `state` has open.
 func PrintDbStats(state *zdelib.State, _ *CommandRegistry, _ string) error {
 	stats := state.DbStats()
 
-	headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
-	columnFmt := color.New(color.FgYellow).SprintfFunc()
-
-	tbl := table.New("Property", "Value", "Description")
-	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+	tbl := NewResult("property", "value", "description")
 
 	tbl.AddRow("FreePageN", stats.FreePageN, "total number of free pages on the freelist")
 	tbl.AddRow("PendingPageN", stats.PendingPageN, "total number of pending pages on the freelist")
@@ -133,22 +152,14 @@ func PrintDbStats(state *zdelib.State, _ *CommandRegistry, _ string) error {
 	tbl.AddRow("TxStats.Write", stats.TxStats.Write, "number of writes performed")
 	tbl.AddRow("TxStats.WriteTime", stats.TxStats.WriteTime, "total time spent writing to disk")
 
-	println("")
-	tbl.Print()
-	println("")
-
-	return nil
+	return Render(tbl)
 }
 
 // PrintBucketStats is an ActionHandler that will print the provided `state`'s current bucket location's stats.
 func PrintBucketStats(state *zdelib.State, _ *CommandRegistry, _ string) error {
 	stats := state.BucketStats()
 
-	headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
-	columnFmt := color.New(color.FgYellow).SprintfFunc()
-
-	tbl := table.New("Property", "Value", "Description")
-	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+	tbl := NewResult("property", "value", "description")
 
 	// Page count statistics.
 	tbl.AddRow("BranchPageN", stats.BranchPageN, " number of logical branch pages")
@@ -171,27 +182,75 @@ func PrintBucketStats(state *zdelib.State, _ *CommandRegistry, _ string) error {
 	tbl.AddRow("InlineBucketN", stats.InlineBucketN, " total number on inlined buckets")
 	tbl.AddRow("InlineBucketInuse", stats.InlineBucketInuse, " bytes used for inlined buckets (also accounted for in LeafInuse)")
 
-	println("")
-	tbl.Print()
-	println("")
-
-	return nil
+	return Render(tbl)
 }
 
 // PrintPath is an ActionHandler that will print the provided `state`'s bucket location.
 func PrintPath(state *zdelib.State, _ *CommandRegistry, _ string) error {
-	if len(state.Path) == 0 {
-		println("root")
-	} else {
-		println(strings.Join(state.Path, "."))
+	path := state.Path.String()
+
+	result := NewResult("path").AddRow(path)
+	result.Text = func(out io.Writer) {
+		_, _ = fmt.Fprintln(out, path)
 	}
 
+	return Render(result)
+}
+
+// RefreshState is an ActionHandler that discards the provided `state`'s cached listings and counts so they are
+// read from the database again.
+func RefreshState(state *zdelib.State, _ *CommandRegistry, _ string) error {
+	state.Refresh()
 	return nil
 }
 
+const (
+	snapshotPin     = "pin"
+	snapshotUnpin   = "unpin"
+	snapshotRefresh = "refresh"
+)
+
+// ManageSnapshot is an ActionHandler that pins the provided `state` to a single read transaction, unpins it, or
+// moves the pinned transaction forward to the latest data. Without arguments the current snapshot is printed.
+func ManageSnapshot(state *zdelib.State, _ *CommandRegistry, args string) error {
+	var err error
+
+	switch action := strings.TrimSpace(args); action {
+	case "":
+	case snapshotPin:
+		err = state.Pin()
+	case snapshotUnpin:
+		err = state.Unpin()
+	case snapshotRefresh:
+		err = state.RefreshPin()
+	default:
+		return fmt.Errorf("unknown snapshot action %s, must be %s, %s or %s", action, snapshotPin, snapshotUnpin, snapshotRefresh)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var txId interface{}
+	if state.Pinned() {
+		txId = state.PinnedTxId()
+	}
+
+	result := NewResult("pinned", "txid").AddRow(state.Pinned(), txId)
+	result.Text = func(out io.Writer) {
+		if state.Pinned() {
+			_, _ = fmt.Fprintf(out, "pinned to tx %d\n", state.PinnedTxId())
+		} else {
+			_, _ = fmt.Fprintln(out, "not pinned, every command reads the latest data")
+		}
+	}
+
+	return Render(result)
+}
+
 // NavToRoot is an ActionHandler that will navigate the provided `state` to the root bucket.
 func NavToRoot(state *zdelib.State, _ *CommandRegistry, _ string) error {
-	state.Path = []string{}
+	state.SetPath(nil)
 	return nil
 }
 
@@ -205,14 +264,56 @@ func PathPrompt(state *zdelib.State) string {
 		promptString = "root"
 		break
 	case size <= 4:
-		promptString = strings.Join(state.Path, ".")
+		promptString = state.Path.String()
 		break
 	default:
-		promptString = state.Path[0] + "..." + strings.Join(state.Path[len(state.Path)-3:], ".")
+		promptString = state.Path[0:1].String() + zdelib.PathSeparator + "..." + state.Path[len(state.Path)-3:].String()
 	}
+
+	if state.Pinned() {
+		promptString += fmt.Sprintf(" [tx %d]", state.PinnedTxId())
+	}
+
+	if state.InTransaction() {
+		promptString += fmt.Sprintf(" [%d staged]", len(state.Pending()))
+	}
+
 	return promptString + ">"
 }
 
+// NavPrevious is an ActionHandler that will move the provided `state` to the previously visited location.
+func NavPrevious(state *zdelib.State, _ *CommandRegistry, _ string) error {
+	return state.Previous()
+}
+
+// NavNext is an ActionHandler that will move the provided `state` forward to the next visited location after using
+// NavPrevious.
+func NavNext(state *zdelib.State, _ *CommandRegistry, _ string) error {
+	return state.Next()
+}
+
+// PrintDirs is an ActionHandler that will print the locations visited by the provided `state`, marking the current
+// location.
+func PrintDirs(state *zdelib.State, _ *CommandRegistry, _ string) error {
+	result := NewResult("index", "path", "current")
+
+	for i, path := range state.History {
+		result.AddRow(i+1, path.String(), i == state.HistoryIndex())
+	}
+
+	result.Text = func(out io.Writer) {
+		for _, row := range result.Rows {
+			marker := " "
+			if row[2] == true {
+				marker = "*"
+			}
+			_, _ = fmt.Fprintf(out, "%s %3d  %s\n", marker, row[0], row[1])
+		}
+	}
+
+	return Render(result)
+}
+
 // NavBackOne is an ActionHandler that will navigate the provided `state` one bucket level back if possible.
 func NavBackOne(state *zdelib.State, _ *CommandRegistry, _ string) error {
 	return state.Back()
@@ -222,61 +323,226 @@ func NavBackOne(state *zdelib.State, _ *CommandRegistry, _ string) error {
 // and values. If limit is zero or negative all values will be listed. A positive limit will only show that number
 // of keys and values. Skip must be 0 or greater and will skip that number of keys.
 func ListCurrentBucketWithLimits(state *zdelib.State, skip int64, limit int64) error {
+	return ListCurrentBucketWithOptions(state, &ListOptions{Skip: skip, Limit: limit})
+}
+
+// ListOptions filters, sorts and limits the entries printed by ListCurrentBucketWithOptions. Skip and Limit are
+// applied after filtering and sorting.
+type ListOptions struct {
+	Skip  int64
+	Limit int64
+
+	// Prefix only lists keys starting with the prefix
+	Prefix string
+
+	// Match only lists keys matching the expression
+	Match *regexp.Regexp
+
+	// Type only lists values of a boltz type name or, if it is "bucket", buckets
+	Type string
+
+	// ValueMatch only lists values whose string form matches the expression
+	ValueMatch *regexp.Regexp
+
+	// Sort is one of the listSort values, entries are listed in key order if it is empty
+	Sort string
+
+	Reverse bool
+
+	// From, After, To and Before limit the range of keys listed
+	From   string
+	After  string
+	To     string
+	Before string
+
+	// Continue is a token printed by a previous listing to continue from
+	Continue string
+}
+
+const (
+	listSortKey   = "key"
+	listSortType  = "type"
+	listSortValue = "value"
+	listSortSize  = "size"
+
+	listTypeBucket = "bucket"
+)
+
+// filtered returns true if any of the options that filter entries are set.
+func (options *ListOptions) filtered() bool {
+	return options.Prefix != "" || options.Match != nil || options.Type != "" || options.ValueMatch != nil ||
+		options.From != "" || options.After != "" || options.To != "" || options.Before != ""
+}
+
+// rangeOptions returns the zdelib.RangeOptions selecting the keys to scan.
+func (options *ListOptions) rangeOptions() (*zdelib.RangeOptions, error) {
+	rangeOptions := &zdelib.RangeOptions{
+		From:   optionalKey(options.From),
+		After:  optionalKey(options.After),
+		To:     optionalKey(options.To),
+		Before: optionalKey(options.Before),
+		Prefix: optionalKey(options.Prefix),
+	}
+
+	if options.Sort == "" {
+		rangeOptions.Reverse = options.Reverse
+	}
+
+	if options.Continue != "" {
+		if options.Sort != "" {
+			return nil, errors.New("--continue cannot be used with --sort")
+		}
+
+		start, err := base64.RawURLEncoding.DecodeString(options.Continue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid continue token: %w", err)
+		}
+		rangeOptions.Start = start
+	}
+
+	return rangeOptions, nil
+}
+
+// optionalKey returns the key bytes for a key flag, nil if it was not set.
+func optionalKey(key string) []byte {
+	if key == "" {
+		return nil
+	}
+	return []byte(key)
+}
+
+// matcher returns a function that returns true for the entries that match the filters in `options`.
+func (options *ListOptions) matcher() (func(entry *zdelib.Entry) bool, error) {
+	var fieldType *boltz.FieldType
+
+	if options.Type != "" && options.Type != listTypeBucket {
+		parsedType, err := zdelib.ParseType(options.Type)
+		if err != nil {
+			return nil, err
+		}
+		fieldType = &parsedType
+	}
+
+	return func(entry *zdelib.Entry) bool {
+		if options.Match != nil && !options.Match.MatchString(entry.Name) {
+			return false
+		}
+
+		if options.Type == listTypeBucket && !entry.IsBucket {
+			return false
+		}
+
+		if fieldType != nil && (entry.IsBucket || entry.Type != *fieldType) {
+			return false
+		}
+
+		if options.ValueMatch != nil && (entry.IsBucket || !options.ValueMatch.MatchString(entryValueString(entry))) {
+			return false
+		}
+
+		return true
+	}, nil
+}
+
+// ListCurrentBucketWithOptions will print a table of the provided `state`'s location's keys and values that match
+// `options`. Unless the entries are sorted, the bucket is read with a cursor starting at the first key in range and
+// stops once the limit is reached, printing a token that continues the listing from the next key.
+func ListCurrentBucketWithOptions(state *zdelib.State, options *ListOptions) error {
+	limit := options.Limit
 	if limit <= 0 {
 		limit = -1
 	}
 
-	if skip < 0 {
+	skip := options.Skip
+	if skip < 0 || options.Continue != "" {
+		// the token already points past the skipped keys of the first page
 		skip = 0
 	}
-	entries := state.ListEntries()
 
-	headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
-	columnFmt := color.New(color.FgYellow).SprintfFunc()
+	matches, err := options.matcher()
+
+	if err != nil {
+		return err
+	}
+
+	rangeOptions, err := options.rangeOptions()
 
-	outTable := table.New("Key", "Type", "Value")
-	outTable.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+	if err != nil {
+		return err
+	}
 
-	bucketStr := "..."
-	nilStr := "<nil>"
+	result := NewResult("key", "type", "value")
+	result.MaxCellWidth = 50
+	result.Empty = "...dust"
 
+	numMatched := int64(0)
 	numSkipped := int64(0)
 	numOutput := int64(0)
+	var next []byte
+
+	if options.Sort != "" {
+		var entries []zdelib.Entry
 
-	for _, entry := range entries {
-		if numSkipped < skip {
-			numSkipped++
-			continue
+		err = state.ScanRange(state.Path, rangeOptions, func(_ []byte, entry zdelib.Entry) bool {
+			if matches(&entry) {
+				entries = append(entries, entry)
+			}
+			return true
+		})
+
+		if err != nil {
+			return err
 		}
 
-		if limit != -1 && numOutput > limit {
-			break
+		if err := sortEntries(entries, options.Sort, options.Reverse); err != nil {
+			return err
 		}
 
-		numOutput++
+		numMatched = int64(len(entries))
 
-		if entry.Type == 0 || entry.Type == boltz.TypeNil {
-			entry.TypeString = "Bucket"
+		for _, entry := range entries {
+			if numSkipped < skip {
+				numSkipped++
+				continue
+			}
 
-			entry.ValueString = &bucketStr
-		}
+			if limit != -1 && numOutput >= limit {
+				break
+			}
 
-		if entry.ValueString == nil {
-			entry.ValueString = &nilStr
+			numOutput++
+
+			entry := entry
+			result.AddRow(entry.Name, entryTypeString(&entry), entryValue(&entry))
 		}
+	} else {
+		err = state.ScanRange(state.Path, rangeOptions, func(key []byte, entry zdelib.Entry) bool {
+			if !matches(&entry) {
+				return true
+			}
 
-		valLen := 50
+			if numSkipped < skip {
+				numSkipped++
+				return true
+			}
+
+			if limit != -1 && numOutput >= limit {
+				next = append([]byte{}, key...)
+				return false
+			}
 
-		valString := *entry.ValueString
+			numMatched++
+			numOutput++
+			result.AddRow(entry.Name, entryTypeString(&entry), entryValue(&entry))
 
-		valString = strings.Replace(valString, "\n", "\\n", -1)
-		valString = strings.Replace(valString, "\t", "\\t", -1)
+			return true
+		})
 
-		if len(valString) < valLen {
-			valLen = len(valString)
+		if err != nil {
+			return err
 		}
 
-		outTable.AddRow(entry.Name, entry.TypeString, valString[0:valLen])
+		numMatched += numSkipped
 	}
 
 	limitStr := "no limit"
@@ -284,50 +550,222 @@ func ListCurrentBucketWithLimits(state *zdelib.State, skip int64, limit int64) e
 		limitStr = strconv.FormatInt(limit, 10)
 	}
 
-	println("")
-	outTable.Print()
+	footer := fmt.Sprintf("skipped: %d, limit: %s", skip, limitStr)
+	if options.filtered() && next == nil {
+		footer = fmt.Sprintf("matched: %d, %s", numMatched, footer)
+	}
+	result.Footer = append(result.Footer, footer)
 
-	if len(entries) == 0 {
-		println("...dust")
+	if next != nil {
+		result.Notes = append(result.Notes, fmt.Sprintf("more keys, continue with: %s %s", ArgContinue, base64.RawURLEncoding.EncodeToString(next)))
+	}
+
+	return Render(result)
+}
+
+// TailCurrentBucket is an ActionHandler that will print the last keys of the provided `state`'s location, 10 unless
+// a count is supplied. Only the keys printed are read.
+func TailCurrentBucket(state *zdelib.State, _ *CommandRegistry, args string) error {
+	count := int64(10)
+
+	if args = strings.TrimSpace(args); args != "" {
+		parsed, err := strconv.ParseInt(args, 10, 64)
+		if err != nil || parsed <= 0 {
+			return fmt.Errorf("invalid count %s, must be a positive integer", args)
+		}
+		count = parsed
+	}
+
+	var entries []zdelib.Entry
+
+	err := state.ScanRange(state.Path, &zdelib.RangeOptions{Reverse: true}, func(_ []byte, entry zdelib.Entry) bool {
+		entries = append(entries, entry)
+		return int64(len(entries)) < count
+	})
+
+	if err != nil {
+		return err
+	}
+
+	result := NewResult("key", "type", "value")
+	result.MaxCellWidth = 50
+	result.Empty = "...dust"
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		result.AddRow(entries[i].Name, entryTypeString(&entries[i]), entryValue(&entries[i]))
+	}
+
+	return Render(result)
+}
+
+// sortEntries sorts `entries` in place by one of the listSort values. Entries that compare equal keep their key
+// order.
+func sortEntries(entries []zdelib.Entry, by string, reverse bool) error {
+	var less func(a, b *zdelib.Entry) bool
+
+	switch by {
+	case "", listSortKey:
+		less = func(a, b *zdelib.Entry) bool { return a.Name < b.Name }
+	case listSortType:
+		less = func(a, b *zdelib.Entry) bool { return entryTypeString(a) < entryTypeString(b) }
+	case listSortValue:
+		less = func(a, b *zdelib.Entry) bool { return compareValues(entryValue(a), entryValue(b)) < 0 }
+	case listSortSize:
+		less = func(a, b *zdelib.Entry) bool { return len(a.Value) < len(b.Value) }
+	default:
+		return fmt.Errorf("invalid sort %s, must be one of %s, %s, %s or %s", by, listSortKey, listSortType, listSortValue, listSortSize)
 	}
-	println("")
-	fmt.Printf("skipped: %d, limit: %s", skip, limitStr)
-	println("")
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if reverse {
+			return less(&entries[j], &entries[i])
+		}
+		return less(&entries[i], &entries[j])
+	})
 
 	return nil
 }
 
+// compareValues orders two entry values. Numbers and times are compared by value, buckets and nil values sort
+// first and anything else is compared by its string form.
+func compareValues(a, b interface{}) int {
+	rank := func(value interface{}) int {
+		switch value.(type) {
+		case Placeholder, nil:
+			return 0
+		case int32, int64, float64:
+			return 1
+		case time.Time:
+			return 2
+		}
+		return 3
+	}
+
+	if rank(a) != rank(b) {
+		return rank(a) - rank(b)
+	}
+
+	switch rank(a) {
+	case 0:
+		return 0
+	case 1:
+		x, y := toFloat(a), toFloat(b)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case 2:
+		x, y := a.(time.Time), b.(time.Time)
+		switch {
+		case x.Before(y):
+			return -1
+		case x.After(y):
+			return 1
+		}
+		return 0
+	}
+
+	return strings.Compare(textValue(a), textValue(b))
+}
+
+// toFloat converts a numeric entry value to a float64.
+func toFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+// entryValueString returns the string matched against an entry's value. Items of string lists are matched by the
+// item itself.
+func entryValueString(entry *zdelib.Entry) string {
+	if entry.IsListItem {
+		return entry.Name
+	}
+
+	if entry.ValueString == nil {
+		return ""
+	}
+
+	return *entry.ValueString
+}
+
+// entryTypeString returns the type to display for an entry, buckets are displayed as "Bucket".
+func entryTypeString(entry *zdelib.Entry) string {
+	if entry.IsBucket {
+		return "Bucket"
+	}
+
+	return entry.TypeString
+}
+
+// entryValue returns the value to display for an entry. Buckets are shown as "..." in tables and as nil in other
+// formats.
+func entryValue(entry *zdelib.Entry) interface{} {
+	if entry.IsBucket {
+		return Placeholder("...")
+	}
+
+	if entry.ValueString != nil && entry.Type == boltz.TypeString {
+		return *entry.ValueString
+	}
+
+	return zdelib.FieldToValue(entry.Type, entry.Value)
+}
+
 // ListCurrentBucketAll is an ActionHandler that will print a table of the provided `state`'s location's keys
 // and values. It will print all keys from first to last.
 func ListCurrentBucketAll(state *zdelib.State, _ *CommandRegistry, _ string) error {
 	return ListCurrentBucketWithLimits(state, 0, -1)
 }
 
-// ListCurrentBucket is an ActionHandler that will parse `args` in order to call ListCurrentBucketWithLimits.
+// ListCurrentBucket is an ActionHandler that will parse `args` in order to call ListCurrentBucketWithOptions.
 func ListCurrentBucket(state *zdelib.State, _ *CommandRegistry, args string) error {
-	noSpaces := regexp.MustCompile(`\s\s+`)
-	args = noSpaces.ReplaceAllString(args, " ")
-	splits := strings.Split(args, " ")
-	skip := int64(0)
-	limit := int64(100)
-
-	for i, arg := range splits {
-		if arg == "--skip" {
-			if i+1 < len(splits) {
-				if parsed, err := strconv.ParseInt(splits[i+1], 10, 64); err == nil {
-					skip = parsed
-				}
-			}
+	flags := newCommandFlags(CmdList)
+	options := &ListOptions{}
+	flags.Int64Var(&options.Skip, "skip", 0, "keys to skip")
+	flags.Int64Var(&options.Limit, "limit", 100, "keys to list, -1 for no limit")
+	flags.StringVar(&options.Prefix, "prefix", "", "only list keys with this prefix")
+	match := flags.String("match", "", "only list keys matching this regex")
+	flags.StringVar(&options.Type, "type", "", "only list values of this type, or buckets")
+	valueMatch := flags.String("value-match", "", "only list values matching this regex")
+	flags.StringVar(&options.Sort, "sort", "", "sort by key, type, value or size")
+	flags.BoolVar(&options.Reverse, "reverse", false, "reverse the order")
+	flags.StringVar(&options.From, "from", "", "first key to list")
+	flags.StringVar(&options.After, "after", "", "list keys after this key")
+	flags.StringVar(&options.To, "to", "", "last key to list")
+	flags.StringVar(&options.Before, "before", "", "list keys before this key")
+	flags.StringVar(&options.Continue, "continue", "", "token printed by a previous list to continue from")
+
+	positional, err := parseCommandFlags(flags, args)
+
+	if err != nil {
+		return err
+	}
+
+	if len(positional) > 0 {
+		return fmt.Errorf("unexpected argument: %s", positional[0])
+	}
+
+	if *match != "" {
+		if options.Match, err = regexp.Compile(*match); err != nil {
+			return fmt.Errorf("invalid match regular expression: %w", err)
 		}
+	}
 
-		if arg == "--limit" {
-			if i+1 < len(splits) {
-				if parsed, err := strconv.ParseInt(splits[i+1], 10, 64); err == nil {
-					limit = parsed
-				}
-			}
+	if *valueMatch != "" {
+		if options.ValueMatch, err = regexp.Compile(*valueMatch); err != nil {
+			return fmt.Errorf("invalid value-match regular expression: %w", err)
 		}
 	}
 
-	return ListCurrentBucketWithLimits(state, skip, limit)
+	return ListCurrentBucketWithOptions(state, options)
 }
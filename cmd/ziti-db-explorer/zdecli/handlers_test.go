@@ -0,0 +1,249 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/openziti/storage/boltz"
+	"github.com/openziti/ziti-db-explorer/zdelib"
+	"go.etcd.io/bbolt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestState creates a database in a temporary directory with a `keys` bucket holding `values` and returns a
+// State positioned in that bucket that is closed when the test ends.
+func newTestState(t *testing.T, values map[string]string) *zdelib.State {
+	t.Helper()
+
+	state := newTestStateWith(t, func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucket([]byte("keys"))
+		if err != nil {
+			return err
+		}
+
+		for key, value := range values {
+			encoded, err := zdelib.EncodeValue(boltz.TypeString, value)
+			if err != nil {
+				return err
+			}
+
+			if err := bucket.Put([]byte(key), encoded); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err := state.EnterPath("/keys"); err != nil {
+		t.Fatal(err)
+	}
+
+	return state
+}
+
+// newTestStateWith creates a database in a temporary directory, populates it with `setup` and returns a read only
+// State at its root that is closed when the test ends.
+func newTestStateWith(t *testing.T, setup func(tx *bbolt.Tx) error) *zdelib.State {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := bbolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatalf("could not create db: %v", err)
+	}
+
+	if err := db.Update(setup); err != nil {
+		t.Fatalf("could not populate db: %v", err)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := zdelib.NewStateWithOptions(path, &zdelib.OpenOptions{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		t.Fatalf("could not open db: %v", err)
+	}
+	t.Cleanup(state.Done)
+
+	return state
+}
+
+// putValues creates the buckets along `path`, including any missing parents, and stores `values` in the last one as
+// boltz typed values.
+func putValues(tx *bbolt.Tx, path zdelib.Path, values map[string]interface{}) error {
+	bucket, err := tx.CreateBucketIfNotExists([]byte(path[0]))
+	if err != nil {
+		return err
+	}
+
+	for _, name := range path[1:] {
+		if bucket, err = bucket.CreateBucketIfNotExists([]byte(name)); err != nil {
+			return err
+		}
+	}
+
+	for key, value := range values {
+		fieldType := boltz.TypeString
+		switch value.(type) {
+		case bool:
+			fieldType = boltz.TypeBool
+		case int64:
+			fieldType = boltz.TypeInt64
+		}
+
+		encoded, err := zdelib.EncodeValue(fieldType, value)
+		if err != nil {
+			return err
+		}
+
+		if err := bucket.Put([]byte(key), encoded); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// execute runs `input` with the default registry and returns what was written to Stdout and Stderr.
+func execute(t *testing.T, state *zdelib.State, input string) (string, string) {
+	t.Helper()
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	previousOut, previousErr := Stdout, Stderr
+	Stdout, Stderr = stdout, stderr
+	defer func() { Stdout, Stderr = previousOut, previousErr }()
+
+	if err := Execute(state, NewDefaultRegistry(), input); err != nil {
+		t.Fatalf("%s: %v", input, err)
+	}
+
+	return stdout.String(), stderr.String()
+}
+
+// jsonKeys returns the `key` field of each row of JSON output.
+func jsonKeys(t *testing.T, output string) []string {
+	t.Helper()
+
+	var rows []map[string]interface{}
+	if err := json.Unmarshal([]byte(output), &rows); err != nil {
+		t.Fatalf("invalid json output %q: %v", output, err)
+	}
+
+	var keys []string
+	for _, row := range rows {
+		keys = append(keys, row["key"].(string))
+	}
+
+	return keys
+}
+
+func TestListFilterAndSort(t *testing.T) {
+	state := newTestState(t, map[string]string{"id0": "bob 1", "id1": "alice", "idx": "a.b", "name": "carol 22"})
+
+	tests := []struct {
+		args string
+		want []string
+	}{
+		{``, []string{"id0", "id1", "idx", "name"}},
+		{`--prefix id`, []string{"id0", "id1", "idx"}},
+		{`--match ^id[0-9]$`, []string{"id0", "id1"}},
+		{`--value-match [0-9]`, []string{"id0", "name"}},
+		{`--match ^id --value-match ^a`, []string{"id1", "idx"}},
+		{`--type string --prefix n`, []string{"name"}},
+		{`--type int64`, nil},
+		{`--sort value`, []string{"idx", "id1", "id0", "name"}},
+		{`--sort size --reverse`, []string{"name", "id0", "id1", "idx"}},
+		{`--reverse --skip 1 --limit 2`, []string{"idx", "id1"}},
+	}
+
+	for _, test := range tests {
+		stdout, _ := execute(t, state, "ls "+test.args+" -o json")
+
+		if got := jsonKeys(t, stdout); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ls %s: got %v, want %v", test.args, got, test.want)
+		}
+	}
+}
+
+func TestListInvalidOptions(t *testing.T) {
+	state := newTestState(t, map[string]string{"a": "1"})
+
+	for _, args := range []string{"--match (a", "--value-match (a", "--sort color"} {
+		if err := Execute(state, NewDefaultRegistry(), "ls "+args); err == nil {
+			t.Errorf("ls %s: expected an error", args)
+		}
+	}
+}
+
+func TestListMatchEscapedPatterns(t *testing.T) {
+	state := newTestState(t, map[string]string{"id0": "bob 1", "id1": "alice", "idx": "a.b", "name": "a1b"})
+
+	tests := []struct {
+		args string
+		want []string
+	}{
+		{`--match "^id\d$"`, []string{"id0", "id1"}},
+		{`--match '^id\d$'`, []string{"id0", "id1"}},
+		{`--match "^id[0-9]$"`, []string{"id0", "id1"}},
+		{`--value-match "\d"`, []string{"id0", "name"}},
+		{`--value-match "^a\.b$"`, []string{"idx"}},
+		{`--match "^id" --value-match "\s"`, []string{"id0"}},
+	}
+
+	for _, test := range tests {
+		stdout, _ := execute(t, state, "ls "+test.args+" -o json")
+
+		if got := jsonKeys(t, stdout); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ls %s: got %v, want %v", test.args, got, test.want)
+		}
+	}
+}
+
+func TestListContinue(t *testing.T) {
+	state := newTestState(t, map[string]string{"a": "1", "b": "2", "c": "3", "d": "4", "e": "5", "f": "6"})
+
+	for _, reverse := range []string{"", " --reverse"} {
+		stdout, _ := execute(t, state, "ls"+reverse+" -o json")
+		all := jsonKeys(t, stdout)
+
+		var got []string
+		command := "ls --skip 1 --limit 2" + reverse
+		args := command
+		for page := 0; page < len(all); page++ {
+			stdout, stderr := execute(t, state, args+" -o json")
+			got = append(got, jsonKeys(t, stdout)...)
+
+			if stderr == "" {
+				break
+			}
+
+			token := strings.TrimSpace(stderr[strings.LastIndex(stderr, " ")+1:])
+			args = command + " " + ArgContinue + " " + token
+		}
+
+		if want := all[1:]; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", command, got, want)
+		}
+	}
+}
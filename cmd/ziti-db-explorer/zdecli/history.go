@@ -0,0 +1,237 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/c-bata/go-prompt"
+	"github.com/openziti/ziti-db-explorer/zdelib"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxHistory is the number of commands kept in the history file.
+const DefaultMaxHistory = 1000
+
+// historyDisabled is printed by PrintHistory when there is no SessionHistory.
+const historyDisabled = "history is disabled"
+
+// SessionHistory is the command history of the interactive session, nil if history is disabled.
+var SessionHistory *CommandHistory
+
+// CommandHistory holds previously entered commands and persists them to a file so that they are available in later
+// sessions.
+type CommandHistory struct {
+	Path       string
+	Entries    []string
+	MaxEntries int
+}
+
+// DefaultHistoryPath returns the location of the history file in the user's config directory.
+func DefaultHistoryPath() string {
+	configDir, err := os.UserConfigDir()
+
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(configDir, "ziti-db-explorer", "history")
+}
+
+// LoadCommandHistory reads the history file at `path`. A missing file results in an empty history.
+func LoadCommandHistory(path string) (*CommandHistory, error) {
+	history := &CommandHistory{
+		Path:       path,
+		MaxEntries: DefaultMaxHistory,
+	}
+
+	file, err := os.Open(path)
+
+	if os.IsNotExist(err) {
+		return history, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			history.Entries = append(history.Entries, line)
+		}
+	}
+
+	if len(history.Entries) > history.MaxEntries {
+		history.Entries = history.Entries[len(history.Entries)-history.MaxEntries:]
+		if err := history.save(); err != nil {
+			return nil, err
+		}
+	}
+
+	return history, scanner.Err()
+}
+
+// Add appends a command to the history and the history file. Blank commands and repeats of the previous command are
+// ignored.
+func (history *CommandHistory) Add(command string) error {
+	command = strings.TrimSpace(command)
+
+	if command == "" || strings.ContainsAny(command, "\r\n") {
+		return nil
+	}
+
+	if len(history.Entries) > 0 && history.Entries[len(history.Entries)-1] == command {
+		return nil
+	}
+
+	history.Entries = append(history.Entries, command)
+
+	if err := os.MkdirAll(filepath.Dir(history.Path), 0700); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(history.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = file.WriteString(command + "\n")
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// save rewrites the history file with the current entries.
+func (history *CommandHistory) save() error {
+	if err := os.MkdirAll(filepath.Dir(history.Path), 0700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(history.Path, []byte(strings.Join(history.Entries, "\n")+"\n"), 0600)
+}
+
+// SearchBackward returns the index of the most recent entry before index `before` that contains `text`. -1 is
+// returned if there is no match.
+func (history *CommandHistory) SearchBackward(text string, before int) int {
+	if before > len(history.Entries) {
+		before = len(history.Entries)
+	}
+
+	for i := before - 1; i >= 0; i-- {
+		if strings.Contains(history.Entries[i], text) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// PromptOptions returns go-prompt options that load the history and bind Ctrl-R to a reverse search of it.
+func (history *CommandHistory) PromptOptions() []prompt.Option {
+	search := &reverseSearch{history: history}
+
+	return []prompt.Option{
+		prompt.OptionHistory(append([]string{}, history.Entries...)),
+		prompt.OptionAddKeyBind(prompt.KeyBind{Key: prompt.ControlR, Fn: search.next}),
+	}
+}
+
+// reverseSearch implements Ctrl-R. The first press searches for the text in the buffer, each following press
+// continues to older matches. Editing the buffer starts a new search.
+type reverseSearch struct {
+	history *CommandHistory
+	query   string
+	index   int
+	match   string
+}
+
+func (search *reverseSearch) next(buf *prompt.Buffer) {
+	if search.match == "" || buf.Text() != search.match {
+		search.query = buf.Text()
+		search.index = len(search.history.Entries)
+	}
+
+	index := search.history.SearchBackward(search.query, search.index)
+
+	if index == -1 {
+		return
+	}
+
+	search.index = index
+	search.match = search.history.Entries[index]
+
+	buf.Delete(len([]rune(buf.Document().TextAfterCursor())))
+	buf.DeleteBeforeCursor(len([]rune(buf.Document().TextBeforeCursor())))
+	buf.InsertText(search.match, false, true)
+}
+
+// PrintHistory is an ActionHandler for `history [--grep <text>] [n]`. It prints the last n commands, 25 by default,
+// optionally limited to those containing the --grep text. If history is disabled, with --no-history or in batch mode,
+// that is printed instead.
+func PrintHistory(_ *zdelib.State, _ *CommandRegistry, args string) error {
+	flags := newCommandFlags(CmdHistory)
+	grep := flags.String("grep", "", "only show commands containing `text`")
+
+	positional, err := parseCommandFlags(flags, args)
+
+	if err != nil {
+		return err
+	}
+
+	count := 25
+	if len(positional) > 0 {
+		if count, err = strconv.Atoi(positional[0]); err != nil {
+			return err
+		}
+	}
+
+	result := NewResult("index", "command")
+
+	if SessionHistory == nil {
+		result.Notes = append(result.Notes, historyDisabled)
+		result.Text = func(out io.Writer) {
+			_, _ = fmt.Fprintln(out, historyDisabled)
+		}
+
+		return Render(result)
+	}
+
+	var matches []int
+	for i, entry := range SessionHistory.Entries {
+		if strings.Contains(entry, *grep) {
+			matches = append(matches, i)
+		}
+	}
+
+	if count > 0 && len(matches) > count {
+		matches = matches[len(matches)-count:]
+	}
+
+	for _, i := range matches {
+		result.AddRow(i+1, SessionHistory.Entries[i])
+	}
+
+	return Render(result)
+}
@@ -0,0 +1,172 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadCommandHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	history, err := LoadCommandHistory(path)
+	if err != nil || len(history.Entries) != 0 {
+		t.Fatalf("expected a missing file to give an empty history, got %v: %v", history, err)
+	}
+
+	lines := []string{"  ls  ", "", "cd /ziti"}
+	for i := 0; i < DefaultMaxHistory; i++ {
+		lines = append(lines, fmt.Sprintf("get k%d", i))
+	}
+
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err = LoadCommandHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(history.Entries) != DefaultMaxHistory {
+		t.Fatalf("expected the history to be trimmed to %d entries, got %d", DefaultMaxHistory, len(history.Entries))
+	}
+
+	// blank lines are skipped and the two oldest commands dropped
+	if first := history.Entries[0]; first != "get k0" {
+		t.Errorf("expected the oldest entries to be dropped, first entry is %q", first)
+	}
+
+	// the trimmed history is saved, so loading it again gives the same entries
+	reloaded, err := LoadCommandHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(reloaded.Entries, history.Entries) {
+		t.Errorf("expected the trimmed history to be saved")
+	}
+}
+
+func TestCommandHistoryAdd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config", "history")
+
+	history, err := LoadCommandHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, command := range []string{"ls", " ls ", "", "cd /ziti", "set a\nb", "ls", "ls"} {
+		if err := history.Add(command); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{"ls", "cd /ziti", "ls"}
+	if !reflect.DeepEqual(history.Entries, want) {
+		t.Errorf("got %v, want %v", history.Entries, want)
+	}
+
+	reloaded, err := LoadCommandHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(reloaded.Entries, want) {
+		t.Errorf("expected the history file to hold %v, got %v", want, reloaded.Entries)
+	}
+}
+
+func TestSearchBackward(t *testing.T) {
+	history := &CommandHistory{Entries: []string{"cd /ziti", "ls", "cd identities", "get name"}}
+
+	tests := []struct {
+		text   string
+		before int
+		want   int
+	}{
+		{"cd", 4, 2},
+		{"cd", 2, 0},
+		{"cd", 0, -1},
+		{"cd", 100, 2},
+		{"", 4, 3},
+		{"rm", 4, -1},
+	}
+
+	for _, test := range tests {
+		if got := history.SearchBackward(test.text, test.before); got != test.want {
+			t.Errorf("SearchBackward(%q, %d): got %d, want %d", test.text, test.before, got, test.want)
+		}
+	}
+}
+
+func TestPrintHistory(t *testing.T) {
+	state := newTestState(t, nil)
+
+	defer func(history *CommandHistory) { SessionHistory = history }(SessionHistory)
+	SessionHistory = &CommandHistory{Entries: []string{"cd /ziti", "ls", "cd identities", "get name"}}
+
+	tests := []struct {
+		args string
+		want []string
+	}{
+		{"", []string{"1 cd /ziti", "2 ls", "3 cd identities", "4 get name"}},
+		{"2", []string{"3 cd identities", "4 get name"}},
+		{"--grep cd", []string{"1 cd /ziti", "3 cd identities"}},
+		{"--grep cd 1", []string{"3 cd identities"}},
+		{"--grep rm", nil},
+	}
+
+	for _, test := range tests {
+		stdout, _ := execute(t, state, "history "+test.args+" -o json")
+
+		var rows []struct {
+			Index   int    `json:"index"`
+			Command string `json:"command"`
+		}
+		if err := json.Unmarshal([]byte(stdout), &rows); err != nil {
+			t.Fatalf("invalid json output %q: %v", stdout, err)
+		}
+
+		var got []string
+		for _, row := range rows {
+			got = append(got, fmt.Sprintf("%d %s", row.Index, row.Command))
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("history %s: got %v, want %v", test.args, got, test.want)
+		}
+	}
+}
+
+func TestPrintHistoryDisabled(t *testing.T) {
+	state := newTestState(t, nil)
+
+	defer func(history *CommandHistory) { SessionHistory = history }(SessionHistory)
+	SessionHistory = nil
+
+	if stdout, _ := execute(t, state, "history"); strings.TrimSpace(stdout) != "history is disabled" {
+		t.Errorf("expected history to be reported as disabled, got %q", stdout)
+	}
+
+	if _, stderr := execute(t, state, "history -o json"); strings.TrimSpace(stderr) != "history is disabled" {
+		t.Errorf("expected history to be reported as disabled on stderr, got %q", stderr)
+	}
+}
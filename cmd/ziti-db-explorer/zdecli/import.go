@@ -0,0 +1,66 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"errors"
+	"fmt"
+	"github.com/openziti/ziti-db-explorer/zdelib"
+	"io"
+	"log"
+	"os"
+)
+
+// RunImport implements the `import <export.json> <new.db>` sub command. It builds a new bbolt database file from a
+// document written by the `export` command. If the export file is `-` the document is read from stdin. With --force an
+// existing db file is replaced, but only once the import has succeeded.
+func RunImport(options *Options) error {
+	if len(options.Args) != 3 {
+		return usageError("import requires an export file and a db file")
+	}
+
+	exportFile := options.Args[1]
+	dbFile := options.Args[2]
+
+	var in io.Reader = os.Stdin
+	if exportFile != "-" {
+		file, err := os.Open(exportFile)
+		if err != nil {
+			return &ExitError{Code: ExitOpenFailed, Err: err}
+		}
+		defer func() { _ = file.Close() }()
+		in = file
+	}
+
+	err := zdelib.ImportFile(dbFile, in, options.Force, options.Timeout)
+
+	var lockErr *zdelib.LockError
+	if errors.As(err, &lockErr) {
+		return &ExitError{Code: ExitOpenFailed, Err: fmt.Errorf("not replacing %s while it is in use: %w", dbFile, lockErr)}
+	}
+
+	if errors.Is(err, os.ErrExist) {
+		return &ExitError{Code: ExitOpenFailed, Err: fmt.Errorf("%w, use --force to replace it", err)}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !options.Quiet {
+		log.Printf("imported %s into %s", exportFile, dbFile)
+	}
+
+	return nil
+}
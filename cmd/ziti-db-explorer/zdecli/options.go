@@ -0,0 +1,173 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"flag"
+	"fmt"
+	"github.com/openziti/ziti-db-explorer/zdelib"
+	"io"
+	"strings"
+	"time"
+)
+
+// Supported values for the --output flag
+const (
+	FormatTable  = "table"
+	FormatJson   = "json"
+	FormatNdJson = "ndjson"
+	FormatYaml   = "yaml"
+	FormatCsv    = "csv"
+)
+
+// OutputFormats lists the values accepted by the --output flag
+var OutputFormats = []string{FormatTable, FormatJson, FormatNdJson, FormatYaml, FormatCsv}
+
+// Options holds the values parsed from the command line.
+type Options struct {
+	Commands    string
+	ScriptFile  string
+	Timeout     time.Duration
+	NoColor     bool
+	Output      string
+	StartPath   string
+	ReadWrite   bool
+	Quiet       bool
+	Force       bool
+	Path        string
+	Yes         bool
+	NoBackup    bool
+	HistoryFile string
+	NoHistory   bool
+	CacheSize   int64
+	Pin         bool
+	Snapshot    bool
+
+	// Args holds the positional arguments left after flags have been parsed
+	Args []string
+}
+
+// flagAliases maps a flag name to the short alias that is registered for it
+var flagAliases = map[string]string{
+	"command": "c",
+	"file":    "f",
+	"output":  "o",
+}
+
+// newFlagSet returns a flag.FlagSet that will parse into `options`.
+func newFlagSet(options *Options) *flag.FlagSet {
+	flags := flag.NewFlagSet(CommandName, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	flags.StringVar(&options.Commands, "command", "", "execute the `commands` separated by ';' and exit")
+	flags.StringVar(&options.ScriptFile, "file", "", "execute the commands in the `script` file and exit")
+	flags.DurationVar(&options.Timeout, "timeout", 2*time.Second, "how long to wait for the db file lock")
+	flags.BoolVar(&options.NoColor, "no-color", false, "disable colored output")
+	flags.StringVar(&options.Output, "output", FormatTable, "output `format`: "+strings.Join(OutputFormats, ", "))
+	flags.StringVar(&options.StartPath, "start-path", "", "bucket `path` to start in (e.g. /ziti/identities)")
+	flags.BoolVar(&options.ReadWrite, "read-write", false, "open the db file for writing, requires exclusive access")
+	flags.BoolVar(&options.Yes, "yes", false, "make changes without asking for confirmation")
+	flags.BoolVar(&options.NoBackup, "no-backup", false, "do not back up the db file before the first write")
+	flags.StringVar(&options.HistoryFile, "history-file", "", "`path` of the command history file (default <user config dir>/ziti-db-explorer/history)")
+	flags.BoolVar(&options.NoHistory, "no-history", false, "do not load or save command history")
+	flags.BoolVar(&options.Snapshot, "snapshot", false, "open a point-in-time copy of the db file, works while the controller is running")
+	flags.BoolVar(&options.Pin, "pin", false, "read every command from one snapshot until 'snapshot refresh'")
+	flags.Int64Var(&options.CacheSize, "cache-size", zdelib.DefaultCacheSize/(1024*1024), "`MiB` of memory used to cache bucket listings, 0 disables caching")
+	flags.BoolVar(&options.Quiet, "quiet", false, "suppress informational log messages")
+	flags.BoolVar(&options.Force, "force", false, "replace an existing db file once an import succeeds")
+	flags.StringVar(&options.Path, "path", "", "bucket `path` to compare when diffing (e.g. /ziti/identities)")
+
+	for name, alias := range flagAliases {
+		f := flags.Lookup(name)
+		flags.Var(f.Value, alias, f.Usage)
+	}
+
+	return flags
+}
+
+// ParseOptions parses command line arguments. Flags may appear before or after positional arguments.
+func ParseOptions(args []string) (*Options, error) {
+	options := &Options{}
+	flags := newFlagSet(options)
+
+	for {
+		if err := flags.Parse(args); err != nil {
+			if err == flag.ErrHelp {
+				options.Args = []string{"help"}
+				return options, nil
+			}
+			return nil, usageError(err.Error())
+		}
+
+		if flags.NArg() == 0 {
+			break
+		}
+
+		options.Args = append(options.Args, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+
+	if options.Commands != "" && options.ScriptFile != "" {
+		return nil, usageError("only one of --command or --file may be supplied")
+	}
+
+	if !isOutputFormat(options.Output) {
+		return nil, usageError(fmt.Sprintf("unsupported output format: %s", options.Output))
+	}
+
+	return options, nil
+}
+
+// isOutputFormat returns true if `format` is one of OutputFormats
+func isOutputFormat(format string) bool {
+	for _, outputFormat := range OutputFormats {
+		if format == outputFormat {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PrintFlags prints the usage of every flag to `out`.
+func PrintFlags(out io.Writer) {
+	flags := newFlagSet(&Options{})
+
+	aliasOf := map[string]string{}
+	for name, alias := range flagAliases {
+		aliasOf[alias] = name
+	}
+
+	flags.VisitAll(func(f *flag.Flag) {
+		if _, isAlias := aliasOf[f.Name]; isAlias {
+			return
+		}
+
+		names := "--" + f.Name
+		if alias, ok := flagAliases[f.Name]; ok {
+			names = "-" + alias + ", " + names
+		}
+
+		argName, usage := flag.UnquoteUsage(f)
+		if argName != "" {
+			names += " <" + argName + ">"
+		}
+
+		if f.DefValue != "" && f.DefValue != "false" {
+			usage += fmt.Sprintf(" (default %s)", f.DefValue)
+		}
+
+		_, _ = fmt.Fprintf(out, "\t%-28s %s\n", names, usage)
+	})
+}
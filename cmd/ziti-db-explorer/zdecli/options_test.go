@@ -0,0 +1,75 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseOptions(t *testing.T) {
+	options, err := ParseOptions([]string{"-o", "json", "ctrl.db", "--timeout", "5s", "-c", "ls; count", "--read-write", "--start-path=/ziti"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"ctrl.db"}; !reflect.DeepEqual(options.Args, want) {
+		t.Errorf("args: got %v, want %v", options.Args, want)
+	}
+
+	if options.Output != FormatJson || options.Timeout != 5*time.Second || options.Commands != "ls; count" ||
+		!options.ReadWrite || options.StartPath != "/ziti" {
+		t.Errorf("unexpected options: %+v", options)
+	}
+
+	options, err = ParseOptions([]string{"diff", "old.db", "--path", "/ziti", "new.db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"diff", "old.db", "new.db"}; !reflect.DeepEqual(options.Args, want) {
+		t.Errorf("args: got %v, want %v", options.Args, want)
+	}
+
+	if options.Output != FormatTable || options.Timeout != 2*time.Second || options.Path != "/ziti" {
+		t.Errorf("unexpected defaults: %+v", options)
+	}
+}
+
+func TestParseOptionsHelp(t *testing.T) {
+	options, err := ParseOptions([]string{"--help"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"help"}; !reflect.DeepEqual(options.Args, want) {
+		t.Errorf("got %v, want %v", options.Args, want)
+	}
+}
+
+func TestParseOptionsErrors(t *testing.T) {
+	tests := [][]string{
+		{"ctrl.db", "--unknown"},
+		{"ctrl.db", "-o", "xml"},
+		{"ctrl.db", "-c", "ls", "-f", "script"},
+		{"ctrl.db", "--timeout", "soon"},
+	}
+
+	for _, args := range tests {
+		if _, err := ParseOptions(args); ExitCode(err) != ExitUsage {
+			t.Errorf("%v: expected a usage error, got %v", args, err)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/rodaine/table"
+	"gopkg.in/yaml.v3"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// OutputFormat is the format Render uses. It is set by the --output flag and may be overridden for a single command
+// by supplying `--output <format>` as an argument to the command.
+var OutputFormat = FormatTable
+
+// Stdout is the io.Writer that Render writes to.
+var Stdout io.Writer = os.Stdout
+
+// Stderr is the io.Writer that Render writes the notes of a Result to when the output format is not a table.
+var Stderr io.Writer = os.Stderr
+
+// Quiet suppresses informational log messages from commands. It is set by the --quiet flag.
+var Quiet = false
+
+// logInfo logs an informational message unless Quiet is set.
+func logInfo(format string, args ...interface{}) {
+	if !Quiet {
+		log.Printf(format, args...)
+	}
+}
+
+// Result is the structured output of a command. Each row holds one value per column. Values are rendered natively
+// by the structured formats (JSON, NDJSON, YAML) and with fmt.Sprint by the text formats (table, CSV).
+type Result struct {
+	Columns []string
+	Rows    [][]interface{}
+
+	// Footer lines are printed after a table and omitted from all other formats
+	Footer []string
+
+	// Notes are printed after the footer of a table and written to Stderr by Render for all other formats, for
+	// information a script needs, such as a continue token, that does not fit the rows
+	Notes []string
+
+	// Empty is printed instead of a table with no rows
+	Empty string
+
+	// MaxCellWidth truncates table cells to the provided width if greater than zero
+	MaxCellWidth int
+
+	// Text, if set, is used to render the table format instead of a table
+	Text func(out io.Writer)
+}
+
+// Placeholder is a value that is shown in tables but is rendered as nil by all other formats.
+type Placeholder string
+
+// NewResult returns an empty Result with the provided column names. Column names are used as-is for field names in
+// the structured formats and are capitalized for table headers.
+func NewResult(columns ...string) *Result {
+	return &Result{
+		Columns: columns,
+	}
+}
+
+// AddRow appends a row of values to the result.
+func (result *Result) AddRow(values ...interface{}) *Result {
+	result.Rows = append(result.Rows, values)
+	return result
+}
+
+// Render writes `result` to Stdout in the current OutputFormat. Notes are written to Stderr unless the format is a
+// table.
+func Render(result *Result) error {
+	if err := RenderAs(OutputFormat, Stdout, result); err != nil {
+		return err
+	}
+
+	if OutputFormat != FormatTable {
+		for _, note := range result.Notes {
+			_, _ = fmt.Fprintln(Stderr, note)
+		}
+	}
+
+	return nil
+}
+
+// RenderAs writes `result` to `out` in the provided format. Notes are only written for the table format.
+func RenderAs(format string, out io.Writer, result *Result) error {
+	switch format {
+	case FormatTable:
+		return renderTable(out, result)
+	case FormatJson:
+		return renderJson(out, result)
+	case FormatNdJson:
+		return renderNdJson(out, result)
+	case FormatYaml:
+		return renderYaml(out, result)
+	case FormatCsv:
+		return renderCsv(out, result)
+	}
+
+	return fmt.Errorf("unsupported output format: %s", format)
+}
+
+// renderTable writes `result` as an aligned, colored text table.
+func renderTable(out io.Writer, result *Result) error {
+	if result.Text != nil {
+		result.Text(out)
+		return nil
+	}
+
+	var headers []interface{}
+	for _, column := range result.Columns {
+		headers = append(headers, capitalize(column))
+	}
+
+	headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+	tbl := table.New(headers...).WithWriter(out)
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+	for _, row := range result.Rows {
+		var cells []interface{}
+		for _, value := range row {
+			cells = append(cells, tableCell(value, result.MaxCellWidth))
+		}
+		tbl.AddRow(cells...)
+	}
+
+	_, _ = fmt.Fprintln(out)
+	tbl.Print()
+
+	if len(result.Rows) == 0 && result.Empty != "" {
+		_, _ = fmt.Fprintln(out, result.Empty)
+	}
+
+	if len(result.Footer) > 0 || len(result.Notes) > 0 {
+		_, _ = fmt.Fprintln(out)
+		for _, line := range append(append([]string{}, result.Footer...), result.Notes...) {
+			_, _ = fmt.Fprintln(out, line)
+		}
+	}
+	_, _ = fmt.Fprintln(out)
+
+	return nil
+}
+
+// renderJson writes `result` as a JSON array with one object per row.
+func renderJson(out io.Writer, result *Result) error {
+	buf := &bytes.Buffer{}
+	buf.WriteString("[")
+
+	for i, row := range result.Rows {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n  ")
+		if err := writeJsonObject(buf, result.Columns, row); err != nil {
+			return err
+		}
+	}
+
+	if len(result.Rows) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("]\n")
+
+	_, err := out.Write(buf.Bytes())
+	return err
+}
+
+// renderNdJson writes `result` as newline delimited JSON with one object per row.
+func renderNdJson(out io.Writer, result *Result) error {
+	buf := &bytes.Buffer{}
+
+	for _, row := range result.Rows {
+		if err := writeJsonObject(buf, result.Columns, row); err != nil {
+			return err
+		}
+		buf.WriteString("\n")
+	}
+
+	_, err := out.Write(buf.Bytes())
+	return err
+}
+
+// writeJsonObject writes a single row as a JSON object, preserving column order.
+func writeJsonObject(buf *bytes.Buffer, columns []string, row []interface{}) error {
+	buf.WriteString("{")
+	for i, column := range columns {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+
+		key, _ := json.Marshal(column)
+		value, err := json.Marshal(structuredValue(row[i]))
+		if err != nil {
+			return err
+		}
+
+		buf.Write(key)
+		buf.WriteString(":")
+		buf.Write(value)
+	}
+	buf.WriteString("}")
+
+	return nil
+}
+
+// renderYaml writes `result` as a YAML sequence with one mapping per row.
+func renderYaml(out io.Writer, result *Result) error {
+	doc := &yaml.Node{Kind: yaml.SequenceNode}
+
+	for _, row := range result.Rows {
+		rowNode := &yaml.Node{Kind: yaml.MappingNode}
+		for i, column := range result.Columns {
+			valueNode := &yaml.Node{}
+			if err := valueNode.Encode(structuredValue(row[i])); err != nil {
+				return err
+			}
+			rowNode.Content = append(rowNode.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: column}, valueNode)
+		}
+		doc.Content = append(doc.Content, rowNode)
+	}
+
+	encoder := yaml.NewEncoder(out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
+
+// renderCsv writes `result` as CSV with a header row.
+func renderCsv(out io.Writer, result *Result) error {
+	writer := csv.NewWriter(out)
+
+	if err := writer.Write(result.Columns); err != nil {
+		return err
+	}
+
+	for _, row := range result.Rows {
+		var record []string
+		for _, value := range row {
+			record = append(record, textValue(value))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// structuredValue converts values that do not marshal in a readable manner.
+func structuredValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case Placeholder:
+		return nil
+	case []byte:
+		return string(v)
+	case time.Duration:
+		return v.String()
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+
+	return value
+}
+
+// textValue converts a value into a string for text formats. nil is rendered as an empty string, lists are
+// separated by commas and maps are rendered as JSON.
+func textValue(value interface{}) string {
+	switch v := structuredValue(value).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, ", ")
+	case map[string]interface{}:
+		if encoded, err := json.Marshal(v); err == nil {
+			return string(encoded)
+		}
+		return fmt.Sprint(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// tableCell converts a value into a string suitable for a table cell, escaping new lines and tabs and truncating
+// it to maxWidth if maxWidth is greater than zero.
+func tableCell(value interface{}, maxWidth int) string {
+	if placeholder, ok := value.(Placeholder); ok {
+		return string(placeholder)
+	}
+
+	if value == nil {
+		return "<nil>"
+	}
+
+	cell := textValue(value)
+	cell = strings.Replace(cell, "\n", "\\n", -1)
+	cell = strings.Replace(cell, "\t", "\\t", -1)
+
+	if maxWidth > 0 && utf8.RuneCountInString(cell) > maxWidth {
+		cell = string([]rune(cell)[0:maxWidth])
+	}
+
+	return cell
+}
+
+// capitalize upper cases the first letter of `s`.
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// extractOutputFlag removes `--output <format>`, `--output=<format>` or `-o <format>` from a command's arguments. The
+// flag is only recognized as the first or last unquoted word(s) of the arguments, so values that contain the flag are
+// left alone. If found, the format is returned along with the remaining arguments, which are otherwise unchanged.
+// Otherwise, an empty format is returned.
+func extractOutputFlag(args string) (string, string, error) {
+	tokens := tokenizeArgs(args)
+
+	if len(tokens) == 0 {
+		return "", args, nil
+	}
+
+	// leading flag
+	if format, size, err := outputFlagAt(args, tokens, 0); err != nil || size > 0 {
+		if err != nil {
+			return "", "", err
+		}
+		return format, args[tokens[size-1].end:], nil
+	}
+
+	// trailing flag, either `--output=<format>` or a flag followed by its format
+	for _, size := range []int{1, 2} {
+		index := len(tokens) - size
+		if index < 0 {
+			continue
+		}
+
+		format, found, err := outputFlagAt(args, tokens, index)
+		if err != nil {
+			return "", "", err
+		}
+
+		if found == size {
+			rest := ""
+			if index > 0 {
+				rest = args[0:tokens[index-1].end]
+			}
+			return format, rest, nil
+		}
+	}
+
+	return "", args, nil
+}
+
+// outputFlagAt checks for an output flag starting at `tokens[index]`. The format and the number of tokens the flag
+// spans are returned, or a size of zero if there is no flag at `index`. Quoted words are never treated as a flag.
+func outputFlagAt(args string, tokens []argToken, index int) (string, int, error) {
+	raw := tokens[index].raw(args)
+	format := ""
+	size := 0
+
+	if strings.HasPrefix(raw, "--output=") {
+		format = tokens[index].value[len("--output="):]
+		size = 1
+	} else if raw == "--output" || raw == "-o" {
+		if index+1 >= len(tokens) {
+			return "", 0, fmt.Errorf("%s requires a format", raw)
+		}
+		format = tokens[index+1].value
+		size = 2
+	} else {
+		return "", 0, nil
+	}
+
+	if !isOutputFormat(format) {
+		return "", 0, fmt.Errorf("unsupported output format: %s", format)
+	}
+
+	return format, size, nil
+}
@@ -0,0 +1,151 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestExtractOutputFlag(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       string
+		wantFormat string
+		wantArgs   string
+	}{
+		{"no flag", ` identities 'name = "al  ice"'`, "", ` identities 'name = "al  ice"'`},
+		{"leading short flag", ` -o json --limit  2`, FormatJson, ` --limit  2`},
+		{"leading long flag", ` --output yaml x`, FormatYaml, ` x`},
+		{"leading equals flag", ` --output=csv x`, FormatCsv, ` x`},
+		{"trailing flag keeps quoting", ` identities 'name = "al  ice"' -o json`, FormatJson, ` identities 'name = "al  ice"'`},
+		{"trailing equals flag", ` a  b --output=ndjson`, FormatNdJson, ` a  b`},
+		{"only flag", ` -o json`, FormatJson, ``},
+		{"flag in the middle is left alone", ` x string hello -o json world`, "", ` x string hello -o json world`},
+		{"quoted flag is left alone", ` x string '-o' json`, "", ` x string '-o' json`},
+		{"quoted value containing flag", ` x string "a -o json"`, "", ` x string "a -o json"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			format, args, err := extractOutputFlag(test.args)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if format != test.wantFormat {
+				t.Errorf("format: got %q, want %q", format, test.wantFormat)
+			}
+
+			if args != test.wantArgs {
+				t.Errorf("args: got %q, want %q", args, test.wantArgs)
+			}
+		})
+	}
+}
+
+func TestExtractOutputFlagErrors(t *testing.T) {
+	for _, args := range []string{` -o`, ` -o xml`, ` a --output=xml`, ` a b -o`} {
+		if _, _, err := extractOutputFlag(args); err == nil {
+			t.Errorf("expected an error for %q", args)
+		}
+	}
+}
+
+func TestRenderAs(t *testing.T) {
+	result := NewResult("key", "value")
+	result.AddRow("a", "x, \"y\"")
+	result.AddRow("b", int64(2))
+	result.AddRow("c", Placeholder("..."))
+	result.AddRow("d", time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC))
+	result.Footer = []string{"footer"}
+	result.Notes = []string{"note"}
+
+	tests := map[string]string{
+		FormatJson: `[
+  {"key":"a","value":"x, \"y\""},
+  {"key":"b","value":2},
+  {"key":"c","value":null},
+  {"key":"d","value":"2022-01-02T03:04:05Z"}
+]
+`,
+		FormatNdJson: `{"key":"a","value":"x, \"y\""}
+{"key":"b","value":2}
+{"key":"c","value":null}
+{"key":"d","value":"2022-01-02T03:04:05Z"}
+`,
+		FormatYaml: `- key: a
+  value: x, "y"
+- key: b
+  value: 2
+- key: c
+  value: null
+- key: d
+  value: "2022-01-02T03:04:05Z"
+`,
+		FormatCsv: `key,value
+a,"x, ""y"""
+b,2
+c,
+d,2022-01-02T03:04:05Z
+`,
+	}
+
+	for format, want := range tests {
+		out := &bytes.Buffer{}
+		if err := RenderAs(format, out, result); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		if got := out.String(); got != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", format, got, want)
+		}
+	}
+
+	if err := RenderAs("xml", &bytes.Buffer{}, result); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
+
+func TestRenderNotes(t *testing.T) {
+	result := NewResult("key").AddRow("a")
+	result.Footer = []string{"footer"}
+	result.Notes = []string{"note"}
+
+	defer func(format string) { OutputFormat = format }(OutputFormat)
+
+	previousOut, previousErr := Stdout, Stderr
+	defer func() { Stdout, Stderr = previousOut, previousErr }()
+
+	for _, format := range []string{FormatTable, FormatJson} {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		Stdout, Stderr = stdout, stderr
+		OutputFormat = format
+
+		if err := Render(result); err != nil {
+			t.Fatal(err)
+		}
+
+		inTable := bytes.Contains(stdout.Bytes(), []byte("note"))
+		if format == FormatTable && (!inTable || stderr.Len() != 0) {
+			t.Errorf("%s: expected the note after the table, stdout %q, stderr %q", format, stdout, stderr)
+		}
+
+		if format != FormatTable && (inTable || stderr.String() != "note\n") {
+			t.Errorf("%s: expected the note on stderr, stdout %q, stderr %q", format, stdout, stderr)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"errors"
+	"fmt"
+	"github.com/openziti/ziti-db-explorer/zdelib"
+	"strings"
+)
+
+const queryUsage = "usage: query <entity-type> [--fields <field,...>] [<filter>]"
+
+// QueryEntities is an ActionHandler that evaluates a Ziti filter, as used by the management API, against the entities
+// of a type and prints the id and selected fields of each match. The filter is the rest of the line after the entity
+// type and may be wrapped in single quotes, e.g. `query identities 'name contains "bob" sort by createdAt desc'`.
+// Supports `--fields <field,...>` before the filter to choose the fields shown.
+func QueryEntities(state *zdelib.State, _ *CommandRegistry, args string) error {
+	entityType, fields, filter, err := parseQueryArgs(args)
+
+	if err != nil {
+		return err
+	}
+
+	queryResult, err := state.QueryEntities(entityType, filter, fields)
+
+	if err != nil {
+		return err
+	}
+
+	result := NewResult(queryResult.Fields...)
+	for _, row := range queryResult.Rows {
+		result.AddRow(row...)
+	}
+
+	result.Empty = "no matching entities"
+	result.Footer = []string{fmt.Sprintf("matched: %d, shown: %d", queryResult.Matched, len(queryResult.Rows))}
+
+	return Render(result)
+}
+
+// parseQueryArgs splits the arguments of `query` into the entity type, the fields listed by --fields and the filter.
+// The filter is taken verbatim, so quotes within it are kept, after removing single or double quotes enclosing it.
+func parseQueryArgs(args string) (string, []string, string, error) {
+	var entityType string
+	var fields []string
+
+	rest := strings.TrimSpace(args)
+
+	for rest != "" {
+		word, remaining := cutWord(rest)
+
+		if word == ArgFields {
+			if remaining == "" {
+				return "", nil, "", fmt.Errorf("%s requires a list of fields", ArgFields)
+			}
+			var list string
+			list, rest = cutWord(remaining)
+			for _, field := range strings.Split(list, ",") {
+				if field = strings.TrimSpace(field); field != "" {
+					fields = append(fields, field)
+				}
+			}
+			continue
+		}
+
+		if entityType != "" {
+			break
+		}
+
+		entityType, rest = word, remaining
+	}
+
+	if entityType == "" {
+		return "", nil, "", errors.New(queryUsage)
+	}
+
+	if len(rest) > 1 && (rest[0] == '\'' || rest[0] == '"') && rest[len(rest)-1] == rest[0] {
+		rest = rest[1 : len(rest)-1]
+	}
+
+	return entityType, fields, rest, nil
+}
+
+// cutWord returns the first whitespace separated word of `s` and the rest of `s` without leading whitespace.
+func cutWord(s string) (string, string) {
+	if index := strings.IndexAny(s, " \t"); index != -1 {
+		return s[0:index], strings.TrimLeft(s[index:], " \t")
+	}
+
+	return s, ""
+}
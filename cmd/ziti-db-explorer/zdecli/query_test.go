@@ -0,0 +1,92 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseQueryArgs(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       string
+		entityType string
+		fields     []string
+		filter     string
+	}{
+		{"type only", " identities ", "identities", nil, ""},
+		{"filter kept verbatim", `identities name = "a  b" and isAdmin = true`, "identities", nil, `name = "a  b" and isAdmin = true`},
+		{"single quotes stripped", `identities 'name contains "bob"'`, "identities", nil, `name contains "bob"`},
+		{"double quotes stripped", `identities "isAdmin = true"`, "identities", nil, `isAdmin = true`},
+		{"inner quotes only kept", `identities name = "bob"`, "identities", nil, `name = "bob"`},
+		{"fields after type", `identities --fields name,tags.env isAdmin = true`, "identities", []string{"name", "tags.env"}, "isAdmin = true"},
+		{"fields before type", `--fields name identities true`, "identities", []string{"name"}, "true"},
+		{"empty fields skipped", `identities --fields ,name,, true`, "identities", []string{"name"}, "true"},
+		{"fields in filter kept", `identities name = "--fields"`, "identities", nil, `name = "--fields"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entityType, fields, filter, err := parseQueryArgs(test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if entityType != test.entityType || !reflect.DeepEqual(fields, test.fields) || filter != test.filter {
+				t.Errorf("parseQueryArgs(%q) = %q, %q, %q, want %q, %q, %q", test.args, entityType, fields, filter, test.entityType, test.fields, test.filter)
+			}
+		})
+	}
+}
+
+func TestParseQueryArgsErrors(t *testing.T) {
+	tests := []struct {
+		args string
+		want string
+	}{
+		{"", queryUsage},
+		{"--fields name", queryUsage},
+		{"identities --fields", "--fields requires a list of fields"},
+	}
+
+	for _, test := range tests {
+		if _, _, _, err := parseQueryArgs(test.args); err == nil || err.Error() != test.want {
+			t.Errorf("parseQueryArgs(%q): expected %q, got %v", test.args, test.want, err)
+		}
+	}
+}
+
+func TestQueryEntities(t *testing.T) {
+	state := newEntityTestState(t)
+
+	stdout, _ := execute(t, state, `query identities --fields name,authPolicyId 'name = "alice"' -o json`)
+
+	if want := `{"id":"id0","name":"alice","authPolicyId":"ap0"}`; !strings.Contains(stdout, want) {
+		t.Errorf("expected %s in output, got %s", want, stdout)
+	}
+
+	stdout, _ = execute(t, state, `query authPolicies name = "none"`)
+
+	if !strings.Contains(stdout, "no matching entities") {
+		t.Errorf("expected no matches, got %s", stdout)
+	}
+
+	for _, input := range []string{"query", "query widgets", `query identities name = `} {
+		if err := Execute(state, NewDefaultRegistry(), input); err == nil {
+			t.Errorf("%s: expected an error", input)
+		}
+	}
+}
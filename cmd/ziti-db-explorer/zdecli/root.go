@@ -13,103 +13,328 @@
 package zdecli
 
 import (
+	"errors"
 	"fmt"
 	"github.com/c-bata/go-prompt"
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
 	"github.com/openziti/ziti-db-explorer/zdelib"
+	"io"
 	"log"
 	"os"
 	"strings"
+	"time"
 )
 
 // CommandName allow usage and such to be altered to fit a hosting executable
 var CommandName = "ziti-db-explorer"
 
+// ErrQuit is returned by the quit ActionHandler to end an interactive or batch session.
+var ErrQuit = errors.New("quit")
+
 // Run is called by main() and is provided so that ziti-db-explorer can be embedded in other CLIs. commandName should
 // be the full command to run ziti-db-explorer (e.g. ziti db explore).
 func Run(commandName, arg string) error {
+	if arg == "" {
+		return RunArgs(commandName, nil)
+	}
+
+	return RunArgs(commandName, []string{arg})
+}
+
+// RunArgs is the same as Run but accepts all command line arguments. See PrintUsage for the supported flags. When
+// --command or --file are not supplied and stdin is not a terminal, commands are read from stdin.
+func RunArgs(commandName string, args []string) error {
 	if commandName != "" {
 		CommandName = commandName
 	}
 
-	if arg == "" {
-		return fmt.Errorf("no path or command supplied")
+	options, err := ParseOptions(args)
+
+	if err != nil {
+		return err
+	}
+
+	if len(options.Args) == 0 {
+		return usageError("no path or command supplied")
 	}
 
-	if arg == "help" {
+	if options.Args[0] == "help" {
 		PrintUsage()
 		return nil
 	}
 
-	if arg == "version" {
+	if options.Args[0] == "version" {
 		PrintVersion()
 		return nil
 	}
 
-	log.Printf("opening db file: %s", arg)
+	if options.NoColor {
+		color.NoColor = true
+	}
+
+	OutputFormat = options.Output
+	AssumeYes = options.Yes
+	Quiet = options.Quiet
+
+	if options.Args[0] == "import" {
+		return RunImport(options)
+	}
+
+	if options.Args[0] == "diff" {
+		return RunDiff(options)
+	}
+
+	if len(options.Args) > 1 {
+		return usageError(fmt.Sprintf("unexpected argument: %s", options.Args[1]))
+	}
+
+	dbFile := options.Args[0]
+
+	if options.Snapshot && options.ReadWrite {
+		return usageError("--snapshot cannot be used with --read-write")
+	}
+
+	var batch io.Reader
+
+	if options.Commands != "" {
+		batch = strings.NewReader(options.Commands)
+	} else if options.ScriptFile != "" {
+		script, err := os.Open(options.ScriptFile)
+		if err != nil {
+			return &ExitError{Code: ExitUsage, Err: err}
+		}
+		defer func() { _ = script.Close() }()
+		batch = script
+	} else if !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+		batch = os.Stdin
+	}
+
+	if !options.Quiet {
+		log.Printf("opening db file: %s", dbFile)
+	}
+
+	openOptions := &zdelib.OpenOptions{
+		Timeout:  options.Timeout,
+		ReadOnly: !options.ReadWrite,
+	}
+
+	var state *zdelib.State
+	if options.Snapshot {
+		state, err = zdelib.NewSnapshotState(dbFile, openOptions)
+	} else {
+		state, err = zdelib.NewStateWithOptions(dbFile, openOptions)
+	}
+
+	var lockErr *zdelib.LockError
+	if errors.As(err, &lockErr) {
+		return &ExitError{Code: ExitOpenFailed, Err: describeLockError(lockErr)}
+	}
+
+	if err != nil {
+		return &ExitError{Code: ExitOpenFailed, Err: err}
+	}
+
+	defer state.Done()
+
+	if options.Snapshot {
+		log.Printf("WARNING: showing a copy of %s taken at %s, changes made after that are not shown",
+			dbFile, state.SnapshotTime.Format(time.RFC3339))
+	}
+
+	state.SkipBackup = options.NoBackup
+	state.SetCacheSize(options.CacheSize * 1024 * 1024)
+
+	if options.Pin {
+		if err := state.Pin(); err != nil {
+			return &ExitError{Code: ExitOpenFailed, Err: err}
+		}
+	}
+
+	if options.ReadWrite && !options.Quiet {
+		log.Printf("db is open for writing, changes are made immediately unless staged with begin")
+	}
+
+	if err := state.EnterPath(options.StartPath); err != nil {
+		return usageError(fmt.Sprintf("invalid start path: %v", err))
+	}
+
+	registry := NewDefaultRegistry()
 
+	if batch != nil {
+		return RunBatch(state, registry, batch)
+	}
+
+	if options.HistoryFile == "" {
+		options.HistoryFile = DefaultHistoryPath()
+	}
+
+	if !options.NoHistory && options.HistoryFile != "" {
+		if SessionHistory, err = LoadCommandHistory(options.HistoryFile); err != nil {
+			log.Printf("could not load command history: %v", err)
+		}
+	}
+
+	RunInteractive(state, registry)
+	return nil
+}
+
+// NewDefaultRegistry returns a CommandRegistry with all the built-in commands added.
+func NewDefaultRegistry() *CommandRegistry {
 	registry := NewCommandRegistry()
 
-	registry.Add(CmdQuit, func(state *zdelib.State, _ *CommandRegistry, _ string) error {
-		os.Exit(0)
-		return nil
+	registry.Add(CmdQuit, func(_ *zdelib.State, _ *CommandRegistry, _ string) error {
+		return ErrQuit
 	})
 
 	registry.Add(CmdList, ListCurrentBucket)
 	registry.Add(CmdListAll, ListCurrentBucketAll)
+	registry.Add(CmdTail, TailCurrentBucket)
 	registry.Add(CmdCd, CdBucket)
 	registry.Add(CmdCount, PrintCurrentCount)
 	registry.Add(CmdBack, NavBackOne)
 	registry.Add(CmdRoot, NavToRoot)
+	registry.Add(CmdPrev, NavPrevious)
+	registry.Add(CmdNext, NavNext)
+	registry.Add(CmdDirs, PrintDirs)
 	registry.Add(CmdPwd, PrintPath)
 	registry.Add(CmdStatsBucket, PrintBucketStats)
 	registry.Add(CmdStatsDb, PrintDbStats)
 	registry.Add(CmdClear, ClearConsole)
 	registry.Add(CmdShow, PrintValue)
 	registry.Add(CmdHelp, PrintHelp)
+	registry.Add(CmdExport, ExportCurrentBucket)
+	registry.Add(CmdTree, PrintTree)
+	registry.Add(CmdFind, FindKeys)
+	registry.Add(CmdGrep, GrepValues)
+	registry.Add(CmdQuery, QueryEntities)
+	registry.Add(CmdDescribe, DescribeEntity)
+	registry.Add(CmdGoto, GotoEntity)
+	registry.Add(CmdFollow, FollowReference)
+	registry.Add(CmdSet, SetValue)
+	registry.Add(CmdRm, DeleteKey)
+	registry.Add(CmdMkBucket, MakeBucket)
+	registry.Add(CmdRmBucket, RemoveBucket)
+	registry.Add(CmdBegin, BeginTransaction)
+	registry.Add(CmdCommit, CommitTransaction)
+	registry.Add(CmdRollback, RollbackTransaction)
+	registry.Add(CmdStatus, PrintTransactionStatus)
+	registry.Add(CmdHistory, PrintHistory)
+	registry.Add(CmdRefresh, RefreshState)
+	registry.Add(CmdSnapshot, ManageSnapshot)
 
-	state, err := zdelib.NewState(arg)
-
-	if err != nil {
-		log.Printf("Error: %v", err)
-		PrintUsage()
-		os.Exit(-1)
-	}
-
-	defer state.Done()
+	return registry
+}
 
+// RunInteractive prompts for and executes commands until the quit command is issued.
+func RunInteractive(state *zdelib.State, registry *CommandRegistry) {
 	completer := &StateCompleter{
 		State:    state,
 		Registry: registry,
 	}
 
-	for true {
-		input := prompt.Input(PathPrompt(state)+" ", completer.Complete, prompt.OptionPrefixTextColor(prompt.Cyan))
-		input = strings.TrimSpace(input)
+	var promptOptions []prompt.Option
+	if !color.NoColor {
+		promptOptions = append(promptOptions, prompt.OptionPrefixTextColor(prompt.Cyan))
+	}
 
-		index := strings.IndexByte(input, ' ')
-		cmd := input
-		args := ""
-		if index != -1 {
-			cmd = input[0:index]
-			args = input[index:]
+	for {
+		inputOptions := promptOptions
+		if SessionHistory != nil {
+			inputOptions = append(SessionHistory.PromptOptions(), promptOptions...)
 		}
 
-		if action, ok := registry.CommandTextToAction[cmd]; ok {
-			if err := action.Do(state, registry, args); err != nil {
-				log.Printf("Error: %v", err)
+		input := prompt.Input(PathPrompt(state)+" ", completer.Complete, inputOptions...)
+
+		if SessionHistory != nil {
+			if err := SessionHistory.Add(input); err != nil {
+				log.Printf("could not save command history: %v", err)
 			}
-		} else {
-			if strings.HasPrefix(input, "\"") && strings.HasSuffix(input, "\"") {
-				input = input[1 : len(input)-1]
+		}
+
+		if err := Execute(state, registry, input); err != nil {
+			if err == ErrQuit {
+				if state.InTransaction() {
+					log.Printf("discarded %d uncommitted change(s)", len(state.Pending()))
+				}
+				return
 			}
+			log.Printf("Error: %v", err)
+		}
+	}
+}
 
-			err := state.Enter(input)
+// Execute parses a single line of input and invokes the matching Action. Input that does not match a command is
+// treated as a bucket name to enter.
+func Execute(state *zdelib.State, registry *CommandRegistry, input string) error {
+	input = strings.TrimSpace(input)
 
-			if err != nil {
-				log.Printf("Error: unknown command or bucket name")
-			}
+	if input == "" {
+		return nil
+	}
+
+	index := strings.IndexByte(input, ' ')
+	cmd := input
+	args := ""
+	if index != -1 {
+		cmd = input[0:index]
+		args = input[index:]
+	}
+
+	if action, ok := registry.CommandTextToAction[cmd]; ok {
+		format, args, err := extractOutputFlag(args)
+
+		if err != nil {
+			return err
 		}
+
+		if format != "" {
+			defer func(previous string) { OutputFormat = previous }(OutputFormat)
+			OutputFormat = format
+		}
+
+		return action.Do(state, registry, args)
+	}
+
+	if err := state.EnterPath(input); err != nil {
+		return fmt.Errorf("unknown command or bucket name: %v", err)
 	}
 
 	return nil
 }
+
+// describeLockError lists the processes holding the lock reported by `lockErr` one per line and suggests how to get
+// past it.
+func describeLockError(lockErr *zdelib.LockError) error {
+	msg := strings.Builder{}
+	msg.WriteString(fmt.Sprintf("could not acquire the lock on %s: %v", lockErr.Path, lockErr.Err))
+
+	if len(lockErr.Holders) == 0 {
+		msg.WriteString("\nno local process holding the lock was found, it may be held from another host or container")
+	}
+
+	explorer := false
+	for _, holder := range lockErr.Holders {
+		msg.WriteString("\n    " + holder.String())
+		explorer = explorer || isExplorerCommand(holder.Command)
+	}
+
+	if explorer {
+		msg.WriteString("\nanother " + CommandName + " session has the file open, quit it or use --snapshot to open a copy")
+	} else {
+		msg.WriteString("\nstop the process holding the lock, e.g. the ziti controller, or use --snapshot to open a copy of the db while it is running")
+	}
+
+	return errors.New(msg.String())
+}
+
+// isExplorerCommand returns true if `command` appears to be another session of this tool. The executable is only
+// compared when running standalone, as an embedding CLI such as `ziti` also runs the controller.
+func isExplorerCommand(command string) bool {
+	if strings.Contains(command, CommandName) {
+		return true
+	}
+
+	fields := strings.Fields(command)
+	return len(fields) > 0 && !strings.Contains(CommandName, " ") && fields[0] == os.Args[0]
+}
@@ -0,0 +1,123 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"errors"
+	"fmt"
+	"github.com/openziti/ziti-db-explorer/zdelib"
+	"io"
+	"strings"
+)
+
+// PrintTree is an ActionHandler that prints the buckets and keys nested within the provided `state`'s current bucket
+// as an indented hierarchy. Supports `--depth <n>` to limit how many bucket levels are descended, `--buckets-only` to
+// skip keys that are not buckets and `--counts` to show the number of keys in each bucket.
+func PrintTree(state *zdelib.State, _ *CommandRegistry, args string) error {
+	flags := newCommandFlags(CmdTree)
+	depth := flags.Int("depth", 0, "bucket levels to descend, 0 for no limit")
+	bucketsOnly := flags.Bool("buckets-only", false, "only show buckets")
+	counts := flags.Bool("counts", false, "show the number of keys in each bucket")
+
+	positional, err := parseCommandFlags(flags, args)
+
+	if err != nil {
+		return err
+	}
+
+	if len(positional) > 0 {
+		return errors.New("tree takes no arguments, cd to the bucket to start from")
+	}
+
+	if *depth < 0 {
+		return errors.New("depth must not be negative")
+	}
+
+	columns := []string{"path", "type"}
+	if *counts {
+		columns = append(columns, "count")
+	}
+	result := NewResult(columns...)
+
+	var lines []treeLine
+
+	err = state.Walk(state.Path, &zdelib.WalkOptions{MaxDepth: *depth, BucketsOnly: *bucketsOnly}, func(entry *zdelib.WalkEntry) error {
+		line := treeLine{depth: entry.Depth, last: entry.Last, name: entry.Entry.Name, isBucket: entry.Bucket != nil}
+
+		row := []interface{}{entry.Path.Child(entry.Entry.Name).String(), entryTypeString(&entry.Entry)}
+		if *counts {
+			var count interface{}
+			if line.isBucket {
+				line.count = zdelib.CountKeys(entry.Bucket)
+				count = line.count
+			}
+			row = append(row, count)
+		}
+		result.AddRow(row...)
+		lines = append(lines, line)
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	result.Text = func(out io.Writer) {
+		_, _ = fmt.Fprintln(out, state.Path.String())
+
+		// lastAtDepth tracks whether the most recent entry at each depth was the last in its bucket, which decides if
+		// a line continues down to later entries
+		var lastAtDepth []bool
+
+		for _, line := range lines {
+			lastAtDepth = append(lastAtDepth[0:line.depth-1], line.last)
+
+			indent := strings.Builder{}
+			for _, last := range lastAtDepth[0 : line.depth-1] {
+				if last {
+					indent.WriteString("    ")
+				} else {
+					indent.WriteString("│   ")
+				}
+			}
+
+			branch := "├── "
+			if line.last {
+				branch = "└── "
+			}
+
+			name := zdelib.QuoteSegment(line.name)
+			if line.isBucket {
+				name += zdelib.PathSeparator
+				if *counts {
+					name += fmt.Sprintf(" (%d)", line.count)
+				}
+			}
+
+			_, _ = fmt.Fprintln(out, indent.String()+branch+name)
+		}
+	}
+
+	return Render(result)
+}
+
+// treeLine is a key printed by PrintTree.
+type treeLine struct {
+	depth    int
+	last     bool
+	name     string
+	isBucket bool
+	count    int64
+}
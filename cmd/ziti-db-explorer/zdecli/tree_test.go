@@ -0,0 +1,97 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"github.com/openziti/ziti-db-explorer/zdelib"
+	"go.etcd.io/bbolt"
+	"strings"
+	"testing"
+)
+
+func TestPrintTree(t *testing.T) {
+	state := newTestStateWith(t, func(tx *bbolt.Tx) error {
+		if err := putValues(tx, zdelib.Path{"a", "b"}, map[string]interface{}{"c": "1"}); err != nil {
+			return err
+		}
+		if err := putValues(tx, zdelib.Path{"a", "b", "d"}, map[string]interface{}{"e": "2"}); err != nil {
+			return err
+		}
+		if err := putValues(tx, zdelib.Path{"a"}, map[string]interface{}{"f": "3"}); err != nil {
+			return err
+		}
+		return putValues(tx, zdelib.Path{"g"}, map[string]interface{}{"h": "4", "a/b": "5"})
+	})
+
+	tests := []struct {
+		args string
+		want string
+	}{
+		{"", `
+/
+├── a/
+│   ├── b/
+│   │   ├── c
+│   │   └── d/
+│   │       └── e
+│   └── f
+└── g/
+    ├── "a/b"
+    └── h
+`},
+		{"--depth 2", `
+/
+├── a/
+│   ├── b/
+│   └── f
+└── g/
+    ├── "a/b"
+    └── h
+`},
+		{"--depth 1", `
+/
+├── a/
+└── g/
+`},
+		{"--buckets-only --counts", `
+/
+├── a/ (2)
+│   └── b/ (2)
+│       └── d/ (1)
+└── g/ (2)
+`},
+	}
+
+	for _, test := range tests {
+		stdout, _ := execute(t, state, "tree "+test.args)
+
+		if got, want := strings.TrimSpace(stdout), strings.TrimSpace(test.want); got != want {
+			t.Errorf("tree %s: got\n%s\nwant\n%s", test.args, got, want)
+		}
+	}
+
+	if err := state.EnterPath("/a/b"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `
+/a/b
+├── c
+└── d/
+    └── e
+`
+	if stdout, _ := execute(t, state, "tree"); strings.TrimSpace(stdout) != strings.TrimSpace(want) {
+		t.Errorf("tree in /a/b: got\n%s\nwant\n%s", stdout, want)
+	}
+}
@@ -0,0 +1,260 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-isatty"
+	"github.com/openziti/ziti-db-explorer/zdelib"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// AssumeYes skips confirmation prompts before writes. It is set by the --yes flag.
+var AssumeYes = false
+
+// SetValue is an ActionHandler for `set <key> <type> <value>`. The value is parsed according to the type and stored
+// with the boltz typed encoding in the current bucket. The value is the rest of the line after the type as it was
+// written, so JSON and repeated spaces are kept, unless it is a single quoted word, which is unquoted.
+func SetValue(state *zdelib.State, _ *CommandRegistry, args string) error {
+	tokens := tokenizeArgs(args)
+
+	if len(tokens) < 2 {
+		return errors.New("usage: set <key> <type> <value>")
+	}
+
+	fieldType, err := zdelib.ParseType(tokens[1].value)
+	if err != nil {
+		return err
+	}
+
+	value, err := zdelib.EncodeValue(fieldType, setValueText(args, tokens))
+	if err != nil {
+		return err
+	}
+
+	return applyMutation(state, &zdelib.Mutation{
+		Op:    zdelib.MutationSet,
+		Path:  state.Path,
+		Key:   tokens[0].value,
+		Value: value,
+	})
+}
+
+// setValueText returns the value of a `set` command from its arguments and their `tokens`: the raw text after the
+// type, or the unquoted text if it is a single quoted word.
+func setValueText(args string, tokens []argToken) string {
+	if len(tokens) == 3 && (args[tokens[2].start] == '"' || args[tokens[2].start] == '\'') {
+		return tokens[2].value
+	}
+
+	return strings.TrimLeft(args[tokens[1].end:], " \t")
+}
+
+// DeleteKey is an ActionHandler for `rm <key>`. It removes a value, not a bucket, from the current bucket.
+func DeleteKey(state *zdelib.State, _ *CommandRegistry, args string) error {
+	splits := SplitArgs(args)
+
+	if len(splits) != 1 {
+		return errors.New("usage: rm <key>")
+	}
+
+	return applyMutation(state, &zdelib.Mutation{
+		Op:   zdelib.MutationDelete,
+		Path: state.Path,
+		Key:  splits[0],
+	})
+}
+
+// MakeBucket is an ActionHandler for `mkbucket <name>`. It creates a bucket in the current bucket.
+func MakeBucket(state *zdelib.State, _ *CommandRegistry, args string) error {
+	splits := SplitArgs(args)
+
+	if len(splits) != 1 {
+		return errors.New("usage: mkbucket <name>")
+	}
+
+	return applyMutation(state, &zdelib.Mutation{
+		Op:   zdelib.MutationCreateBucket,
+		Path: state.Path,
+		Key:  splits[0],
+	})
+}
+
+// RemoveBucket is an ActionHandler for `rmbucket [-r] <name>`. It removes a bucket from the current bucket. Buckets
+// that are not empty are only removed with -r.
+func RemoveBucket(state *zdelib.State, _ *CommandRegistry, args string) error {
+	flags := newCommandFlags(CmdRmBucket)
+	recursive := flags.Bool("r", false, "remove the bucket and everything in it")
+
+	positional, err := parseCommandFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) != 1 {
+		return errors.New("usage: rmbucket [-r] <name>")
+	}
+
+	return applyMutation(state, &zdelib.Mutation{
+		Op:        zdelib.MutationDeleteBucket,
+		Path:      state.Path,
+		Key:       positional[0],
+		Recursive: *recursive,
+	})
+}
+
+// BeginTransaction is an ActionHandler for `begin`. Changes made after it are staged until `commit` or `rollback`.
+func BeginTransaction(state *zdelib.State, _ *CommandRegistry, _ string) error {
+	if state.DB.IsReadOnly() {
+		return errReadOnly
+	}
+
+	return state.Begin()
+}
+
+// CommitTransaction is an ActionHandler for `commit`. It previews the staged changes, asks for confirmation and
+// applies them in a single write transaction.
+func CommitTransaction(state *zdelib.State, _ *CommandRegistry, _ string) error {
+	if !state.InTransaction() {
+		return errors.New("no transaction in progress")
+	}
+
+	pending := state.Pending()
+
+	if len(pending) == 0 {
+		return state.Rollback()
+	}
+
+	var descriptions []string
+	for _, mutation := range pending {
+		descriptions = append(descriptions, mutation.String())
+	}
+
+	if ok, err := confirm(strings.Join(descriptions, "\n")); err != nil {
+		return err
+	} else if !ok {
+		return errors.New("aborted, changes remain staged")
+	}
+
+	return withBackupNotice(state, state.Commit)
+}
+
+// RollbackTransaction is an ActionHandler for `rollback`. It discards all staged changes.
+func RollbackTransaction(state *zdelib.State, _ *CommandRegistry, _ string) error {
+	count := len(state.Pending())
+
+	if err := state.Rollback(); err != nil {
+		return err
+	}
+
+	logInfo("discarded %d staged change(s)", count)
+	return nil
+}
+
+// PrintTransactionStatus is an ActionHandler for `status`. It prints the changes staged in the current transaction.
+func PrintTransactionStatus(state *zdelib.State, _ *CommandRegistry, _ string) error {
+	result := NewResult("change")
+
+	for _, mutation := range state.Pending() {
+		result.AddRow(mutation.String())
+	}
+
+	result.Text = func(out io.Writer) {
+		switch {
+		case state.DB.IsReadOnly():
+			_, _ = fmt.Fprintln(out, "db is open read only")
+		case !state.InTransaction():
+			_, _ = fmt.Fprintln(out, "no transaction in progress, changes are applied immediately")
+		default:
+			_, _ = fmt.Fprintf(out, "transaction in progress, %d staged change(s):\n", len(result.Rows))
+			for _, row := range result.Rows {
+				_, _ = fmt.Fprintf(out, "  %s\n", row[0])
+			}
+			_, _ = fmt.Fprintln(out, "staged changes are not visible until committed")
+		}
+	}
+
+	return Render(result)
+}
+
+// errReadOnly is returned when a change is attempted without --read-write.
+var errReadOnly = errors.New("db is open read only, restart with --read-write to make changes")
+
+// applyMutation stages a mutation if a transaction is in progress. Otherwise, the mutation is confirmed and applied
+// immediately.
+func applyMutation(state *zdelib.State, mutation *zdelib.Mutation) error {
+	if state.DB.IsReadOnly() {
+		return errReadOnly
+	}
+
+	if state.InTransaction() {
+		return state.Stage(mutation)
+	}
+
+	if ok, err := confirm(mutation.String()); err != nil {
+		return err
+	} else if !ok {
+		return errors.New("aborted")
+	}
+
+	return withBackupNotice(state, func() error {
+		return state.Apply(mutation)
+	})
+}
+
+// withBackupNotice calls `write` and reports the location of the backup if one was made by it.
+func withBackupNotice(state *zdelib.State, write func() error) error {
+	hadBackup := state.BackupPath != ""
+
+	if err := write(); err != nil {
+		return err
+	}
+
+	if !hadBackup && state.BackupPath != "" {
+		log.Printf("backed up db to %s", state.BackupPath)
+	}
+
+	return nil
+}
+
+// confirm asks the user to confirm `action`, which may span several lines. If AssumeYes is set each line of `action`
+// is logged, even with --quiet so there is a record of the change, and true is returned without asking. If stdin is
+// not a terminal an error is returned as there is no one to ask.
+func confirm(action string) (bool, error) {
+	if AssumeYes {
+		for _, line := range strings.Split(action, "\n") {
+			log.Printf("applying: %s", line)
+		}
+		return true, nil
+	}
+
+	if !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+		return false, errors.New("confirmation required, use --yes to make changes without a terminal")
+	}
+
+	fmt.Printf("%s\nproceed? [y/N] ", action)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
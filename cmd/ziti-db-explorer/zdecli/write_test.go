@@ -0,0 +1,67 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdecli
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSetValueText(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{"no value", " key string", ""},
+		{"word", " key string hello", "hello"},
+		{"spaces kept", " two string a   b", "a   b"},
+		{"json kept", ` cfg string {"a": "b\\c"}`, `{"a": "b\\c"}`},
+		{"double quoted word", ` key string "a  b"`, "a  b"},
+		{"single quoted word", ` key string 'say "hi"'`, `say "hi"`},
+		{"quotes within text kept", ` key string "a" b`, `"a" b`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := setValueText(test.args, tokenizeArgs(test.args)); got != test.want {
+				t.Errorf("setValueText(%q) = %q, want %q", test.args, got, test.want)
+			}
+		})
+	}
+}
+
+func TestConfirmAssumeYesLogsChanges(t *testing.T) {
+	logged := &bytes.Buffer{}
+	log.SetOutput(logged)
+	defer log.SetOutput(os.Stderr)
+
+	defer func(assumeYes, quiet bool) { AssumeYes, Quiet = assumeYes, quiet }(AssumeYes, Quiet)
+	AssumeYes = true
+	Quiet = true
+
+	ok, err := confirm("mkbucket /ziti/a\nset /ziti/a/b = (string) c")
+	if err != nil || !ok {
+		t.Fatalf("expected confirmation without asking, got %v: %v", ok, err)
+	}
+
+	for _, change := range []string{"applying: mkbucket /ziti/a", "applying: set /ziti/a/b = (string) c"} {
+		if !strings.Contains(logged.String(), change) {
+			t.Errorf("expected %q to be logged, got %q", change, logged.String())
+		}
+	}
+}
@@ -0,0 +1,160 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"container/list"
+	"go.etcd.io/bbolt"
+	"os"
+)
+
+// DefaultCacheSize is the default memory budget, in bytes, for cached bucket listings and key counts.
+const DefaultCacheSize = 64 * 1024 * 1024
+
+// cacheItemOverhead approximates the memory used by a cached item beyond its key and value bytes.
+const cacheItemOverhead = 64
+
+// entryOverhead approximates the memory used by an Entry beyond the bytes of its name, value and strings.
+const entryOverhead = 80
+
+// lruCache holds values up to a memory budget, evicting the least recently used values first. The values are only
+// valid for the bbolt transaction ID and the version of the database file they were read at.
+type lruCache struct {
+	budget int64
+	used   int64
+	items  map[string]*list.Element
+	order  *list.List
+	txId   int
+	file   os.FileInfo
+}
+
+// cacheItem is a single value held by an lruCache.
+type cacheItem struct {
+	key   string
+	value interface{}
+	size  int64
+}
+
+// newLruCache returns an empty lruCache with a memory budget of `budget` bytes. A budget of 0 or less disables the
+// cache.
+func newLruCache(budget int64) *lruCache {
+	return &lruCache{
+		budget: budget,
+		items:  map[string]*list.Element{},
+		order:  list.New(),
+	}
+}
+
+// sync clears the cache if `tx` sees a different version of the database than the cached values were read from. The
+// transaction ID alone is not enough, a file replaced or rewritten on disk, e.g. by restoring a backup, may have the
+// same or an older ID, so the cache is also cleared when the file's identity, size or modification time changes.
+func (cache *lruCache) sync(tx *bbolt.Tx) {
+	file, _ := os.Stat(tx.DB().Path())
+
+	if tx.ID() != cache.txId || !sameFileVersion(file, cache.file) {
+		cache.clear()
+		cache.txId = tx.ID()
+		cache.file = file
+	}
+}
+
+// sameFileVersion returns true if `a` and `b` describe the same file with the same size and modification time.
+func sameFileVersion(a, b os.FileInfo) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	return os.SameFile(a, b) && a.Size() == b.Size() && a.ModTime().Equal(b.ModTime())
+}
+
+// get returns the value cached for `key` and marks it as recently used.
+func (cache *lruCache) get(key string) (interface{}, bool) {
+	element, ok := cache.items[key]
+
+	if !ok {
+		return nil, false
+	}
+
+	cache.order.MoveToFront(element)
+	return element.Value.(*cacheItem).value, true
+}
+
+// put caches `value` for `key`, evicting the least recently used values until it fits. Values larger than the
+// budget are not cached.
+func (cache *lruCache) put(key string, value interface{}, size int64) {
+	size += int64(len(key)) + cacheItemOverhead
+
+	if element, ok := cache.items[key]; ok {
+		cache.remove(element)
+	}
+
+	if size > cache.budget {
+		return
+	}
+
+	for cache.used+size > cache.budget {
+		cache.remove(cache.order.Back())
+	}
+
+	cache.items[key] = cache.order.PushFront(&cacheItem{key: key, value: value, size: size})
+	cache.used += size
+}
+
+// remove removes a single element from the cache.
+func (cache *lruCache) remove(element *list.Element) {
+	item := cache.order.Remove(element).(*cacheItem)
+	delete(cache.items, item.key)
+	cache.used -= item.size
+}
+
+// clear removes every value from the cache.
+func (cache *lruCache) clear() {
+	cache.items = map[string]*list.Element{}
+	cache.order.Init()
+	cache.used = 0
+}
+
+// resize changes the memory budget, evicting values that no longer fit.
+func (cache *lruCache) resize(budget int64) {
+	cache.budget = budget
+
+	for cache.used > cache.budget && cache.order.Len() > 0 {
+		cache.remove(cache.order.Back())
+	}
+}
+
+// entriesSize approximates the memory used by `entries`.
+func entriesSize(entries []Entry) int64 {
+	size := int64(0)
+
+	for _, entry := range entries {
+		size += int64(len(entry.Name)+len(entry.Value)+len(entry.TypeString)) + entryOverhead
+		if entry.ValueString != nil {
+			size += int64(len(*entry.ValueString))
+		}
+	}
+
+	return size
+}
+
+// SetCacheSize changes the memory budget, in bytes, for cached bucket listings and key counts. A size of 0 disables
+// caching.
+func (state *State) SetCacheSize(size int64) {
+	state.cache.resize(size)
+}
+
+// Refresh discards all cached bucket listings and key counts so they are read from the database again.
+func (state *State) Refresh() {
+	state.cache.clear()
+}
@@ -0,0 +1,178 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"fmt"
+	"go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLruCache(t *testing.T) {
+	cache := newLruCache(3 * (cacheItemOverhead + 2))
+
+	cache.put("a", 1, 1)
+	cache.put("b", 2, 1)
+	cache.put("c", 3, 1)
+
+	// mark a as recently used so b is evicted first
+	if value, ok := cache.get("a"); !ok || value != 1 {
+		t.Fatalf("expected a to be cached, got %v", value)
+	}
+
+	cache.put("d", 4, 1)
+
+	if _, ok := cache.get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := cache.get(key); !ok {
+			t.Errorf("expected %s to be cached", key)
+		}
+	}
+
+	cache.put("big", 5, cache.budget)
+	if _, ok := cache.get("big"); ok {
+		t.Error("expected a value larger than the budget not to be cached")
+	}
+
+	cache.resize(0)
+	if cache.used != 0 || cache.order.Len() != 0 {
+		t.Errorf("expected resizing to 0 to empty the cache, %d bytes used", cache.used)
+	}
+}
+
+func TestCompletionsAt(t *testing.T) {
+	state := newTestState(t, func(tx *bbolt.Tx) error {
+		small, err := tx.CreateBucket([]byte("small"))
+		if err != nil {
+			return err
+		}
+
+		for _, key := range []string{"b", "a", "c"} {
+			if err := small.Put([]byte(key), []byte("x")); err != nil {
+				return err
+			}
+		}
+
+		if _, err := small.CreateBucket([]byte("nested")); err != nil {
+			return err
+		}
+
+		large, err := tx.CreateBucket([]byte("large"))
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i < MaxCompletions*3; i++ {
+			if err := large.Put([]byte(fmt.Sprintf("k%04d", i)), []byte("x")); err != nil {
+				return err
+			}
+		}
+
+		_, err = large.CreateBucket([]byte("z-bucket"))
+		return err
+	})
+
+	// small buckets return every name regardless of the prefix
+	if got, want := state.CompletionsAt(Path{"small"}, "x", false), []string{"a", "b", "c", "nested"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("small: got %v, want %v", got, want)
+	}
+
+	if got, want := state.CompletionsAt(Path{"small"}, "", true), []string{"nested"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("small buckets only: got %v, want %v", got, want)
+	}
+
+	// large buckets only return names starting with the prefix
+	got := state.CompletionsAt(Path{"large"}, "k251", false)
+	if want := []string{"k2510", "k2511", "k2512", "k2513", "k2514", "k2515", "k2516", "k2517", "k2518", "k2519"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("large: got %v, want %v", got, want)
+	}
+
+	if got := state.CompletionsAt(Path{"large"}, "", false); len(got) != MaxCompletions {
+		t.Errorf("large without prefix: expected %d names, got %d", MaxCompletions, len(got))
+	}
+
+	if got, want := state.CompletionsAt(Path{"large"}, "z", true), []string{"z-bucket"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("large buckets only: got %v, want %v", got, want)
+	}
+
+	// cached results are returned again
+	if got, want := state.CompletionsAt(Path{"large"}, "k251", false), 10; len(got) != want {
+		t.Errorf("large cached: expected %d names, got %d", want, len(got))
+	}
+
+	if got := state.CompletionsAt(Path{"missing"}, "", false); got != nil {
+		t.Errorf("missing: expected nil, got %v", got)
+	}
+}
+
+func TestCacheClearedWhenFileRewritten(t *testing.T) {
+	// two dbs built with the same number of transactions have the same transaction ID
+	build := func(key string) string {
+		path := filepath.Join(t.TempDir(), "test.db")
+
+		db, err := bbolt.Open(path, 0600, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = db.Update(func(tx *bbolt.Tx) error {
+			bucket, err := tx.CreateBucket([]byte("keys"))
+			if err != nil {
+				return err
+			}
+			return bucket.Put([]byte(key), []byte("x"))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		return path
+	}
+
+	path := build("a")
+	restored, err := os.ReadFile(build("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := NewStateWithOptions(path, &OpenOptions{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Done()
+
+	if entries := state.ListEntriesAt(Path{"keys"}); len(entries) != 1 || entries[0].Name != "a" {
+		t.Fatalf("expected a, got %v", entries)
+	}
+
+	// restore a backup over the file in place while it is open
+	if err := os.WriteFile(path, restored, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if entries := state.ListEntriesAt(Path{"keys"}); len(entries) != 1 || entries[0].Name != "b" {
+		t.Errorf("expected the listing of the restored file, got %v", entries)
+	}
+}
@@ -0,0 +1,143 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"bytes"
+	"fmt"
+	"go.etcd.io/bbolt"
+)
+
+// DiffKind describes how a key differs between two databases.
+type DiffKind string
+
+const (
+	DiffAdded   DiffKind = "added"
+	DiffRemoved DiffKind = "removed"
+	DiffChanged DiffKind = "changed"
+)
+
+// Difference is a single key that differs between two databases. Old is nil for added keys and New is nil for
+// removed keys.
+type Difference struct {
+	Kind DiffKind
+	Path Path
+	Key  string
+	Old  *Entry
+	New  *Entry
+}
+
+// Diff compares the bucket at `path` in `oldDb` and `newDb`, walking both with parallel cursors, and calls `report`
+// for every key that was added, removed or changed. Added and removed buckets are reported once without their
+// contents. A key that is a bucket in one database and a value in the other is reported as changed. If `report`
+// returns an error the walk is stopped and the error is returned. If `path` is in neither database, a path written
+// with `.` separators such as `ziti.identities` is accepted as described for State.Resolve.
+func Diff(oldDb, newDb *bbolt.DB, path Path, report func(*Difference) error) error {
+	return oldDb.View(func(oldTx *bbolt.Tx) error {
+		return newDb.View(func(newTx *bbolt.Tx) error {
+			oldBucket := BucketAt(oldTx, path)
+			newBucket := BucketAt(newTx, path)
+
+			if oldBucket == nil && newBucket == nil {
+				dotted := dottedPath(oldTx, path)
+				if dotted == nil {
+					dotted = dottedPath(newTx, path)
+				}
+
+				if dotted == nil {
+					return fmt.Errorf("path not found: %s", path)
+				}
+
+				path = dotted
+				oldBucket = BucketAt(oldTx, path)
+				newBucket = BucketAt(newTx, path)
+			}
+
+			if oldBucket == nil || newBucket == nil {
+				if len(path) == 0 {
+					return nil
+				}
+
+				difference := &Difference{Path: path.Parent(), Key: path[len(path)-1]}
+				bucketEntry := &Entry{Name: difference.Key, IsBucket: true}
+
+				if oldBucket == nil {
+					difference.Kind = DiffAdded
+					difference.New = bucketEntry
+				} else {
+					difference.Kind = DiffRemoved
+					difference.Old = bucketEntry
+				}
+
+				return report(difference)
+			}
+
+			return diffBuckets(oldBucket, newBucket, path, report)
+		})
+	})
+}
+
+// diffBuckets compares two buckets at the same path and recurses into buckets present in both.
+func diffBuckets(oldBucket, newBucket *bbolt.Bucket, path Path, report func(*Difference) error) error {
+	oldCursor := oldBucket.Cursor()
+	newCursor := newBucket.Cursor()
+
+	oldKey, oldValue := oldCursor.First()
+	newKey, newValue := newCursor.First()
+
+	for oldKey != nil || newKey != nil {
+		compare := 0
+		switch {
+		case oldKey == nil:
+			compare = 1
+		case newKey == nil:
+			compare = -1
+		default:
+			compare = bytes.Compare(oldKey, newKey)
+		}
+
+		switch {
+		case compare < 0:
+			oldEntry := NewEntry(oldKey, oldValue)
+			if err := report(&Difference{Kind: DiffRemoved, Path: path, Key: oldEntry.Name, Old: &oldEntry}); err != nil {
+				return err
+			}
+			oldKey, oldValue = oldCursor.Next()
+		case compare > 0:
+			newEntry := NewEntry(newKey, newValue)
+			if err := report(&Difference{Kind: DiffAdded, Path: path, Key: newEntry.Name, New: &newEntry}); err != nil {
+				return err
+			}
+			newKey, newValue = newCursor.Next()
+		default:
+			if oldValue == nil && newValue == nil {
+				childPath := path.Child(string(oldKey))
+				if err := diffBuckets(oldBucket.Bucket(oldKey), newBucket.Bucket(newKey), childPath, report); err != nil {
+					return err
+				}
+			} else if (oldValue == nil) != (newValue == nil) || !bytes.Equal(oldValue, newValue) {
+				oldEntry := NewEntry(oldKey, oldValue)
+				newEntry := NewEntry(newKey, newValue)
+				if err := report(&Difference{Kind: DiffChanged, Path: path, Key: oldEntry.Name, Old: &oldEntry, New: &newEntry}); err != nil {
+					return err
+				}
+			}
+
+			oldKey, oldValue = oldCursor.Next()
+			newKey, newValue = newCursor.Next()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/openziti/storage/boltz"
+	"math"
+	"strconv"
+	"time"
+)
+
+// ParseType converts a type name into a boltz.FieldType. Names returned by TypeToString are accepted as well as
+// "time" and "float".
+func ParseType(name string) (boltz.FieldType, error) {
+	switch name {
+	case "string":
+		return boltz.TypeString, nil
+	case "int32":
+		return boltz.TypeInt32, nil
+	case "int64":
+		return boltz.TypeInt64, nil
+	case "float64", "float":
+		return boltz.TypeFloat64, nil
+	case "bool":
+		return boltz.TypeBool, nil
+	case "date.Time", "time":
+		return boltz.TypeTime, nil
+	case "nil":
+		return boltz.TypeNil, nil
+	}
+
+	return 0, fmt.Errorf("unknown type: %s", name)
+}
+
+// EncodeValue encodes `value` with the boltz typed encoding for `fieldType`. The returned bytes include the type
+// prefix and are suitable to be stored directly in a bucket. Values may be provided as their native Go type, as a
+// json.Number, or as a string which will be parsed.
+func EncodeValue(fieldType boltz.FieldType, value interface{}) ([]byte, error) {
+	switch fieldType {
+	case boltz.TypeString:
+		if str, ok := value.(string); ok {
+			return boltz.PrependFieldType(boltz.TypeString, []byte(str)), nil
+		}
+	case boltz.TypeBool:
+		b, err := toBool(value)
+		if err != nil {
+			return nil, err
+		}
+		buf := []byte{byte(boltz.TypeBool), 0}
+		if b {
+			buf[1] = 1
+		}
+		return buf, nil
+	case boltz.TypeInt32:
+		i, err := toInt(value, 32)
+		if err != nil {
+			return nil, err
+		}
+		return boltz.Int32ToBytes(int32(i)), nil
+	case boltz.TypeInt64:
+		i, err := toInt(value, 64)
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, 9)
+		buf[0] = byte(boltz.TypeInt64)
+		binary.LittleEndian.PutUint64(buf[1:], uint64(i))
+		return buf, nil
+	case boltz.TypeFloat64:
+		f, err := toFloat(value)
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, 9)
+		buf[0] = byte(boltz.TypeFloat64)
+		binary.LittleEndian.PutUint64(buf[1:], math.Float64bits(f))
+		return buf, nil
+	case boltz.TypeTime:
+		t, err := toTime(value)
+		if err != nil {
+			return nil, err
+		}
+		timeBytes, err := t.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		return boltz.PrependFieldType(boltz.TypeTime, timeBytes), nil
+	case boltz.TypeNil:
+		if value == nil || value == "" || value == "nil" {
+			return []byte{byte(boltz.TypeNil)}, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown type: %d", fieldType)
+	}
+
+	return nil, fmt.Errorf("invalid %s value: %v", TypeToString(fieldType), value)
+}
+
+func toBool(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		return strconv.ParseBool(v)
+	}
+
+	return false, fmt.Errorf("invalid bool value: %v", value)
+}
+
+func toInt(value interface{}, bitSize int) (int64, error) {
+	switch v := value.(type) {
+	case int:
+		return toInt(strconv.Itoa(v), bitSize)
+	case int32:
+		return int64(v), nil
+	case int64:
+		return toInt(strconv.FormatInt(v, 10), bitSize)
+	case json.Number:
+		return toInt(v.String(), bitSize)
+	case string:
+		return strconv.ParseInt(v, 10, bitSize)
+	}
+
+	return 0, fmt.Errorf("invalid int%d value: %v", bitSize, value)
+}
+
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case json.Number:
+		return strconv.ParseFloat(v.String(), 64)
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+
+	return 0, fmt.Errorf("invalid float64 value: %v", value)
+}
+
+func toTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		return time.Parse(time.RFC3339Nano, v)
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time value: %v", value)
+}
@@ -0,0 +1,351 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"errors"
+	"fmt"
+	"github.com/openziti/storage/boltz"
+	"go.etcd.io/bbolt"
+	"sort"
+	"strings"
+)
+
+// EntitiesPath is the bucket holding a bucket for each Ziti entity type, each of which holds a bucket per entity keyed
+// by its id.
+var EntitiesPath = Path{"ziti"}
+
+// EntityTypePath returns the path of the bucket holding the entities of `entityType`. A type name such as `identities`
+// is found in EntitiesPath, an expression containing a PathSeparator is parsed as a path.
+func EntityTypePath(entityType string) (Path, error) {
+	if strings.Contains(entityType, PathSeparator) {
+		return ParsePath(entityType)
+	}
+
+	return EntitiesPath.Child(entityType), nil
+}
+
+// EntityTypes returns the names of the entity type buckets in EntitiesPath.
+func (state *State) EntityTypes() []string {
+	var result []string
+
+	for _, entry := range state.ListEntriesAt(EntitiesPath) {
+		if entry.IsBucket {
+			result = append(result, entry.Name)
+		}
+	}
+
+	return result
+}
+
+// entityTypeBucket returns the bucket holding the entities of `entityType`.
+func entityTypeBucket(tx *bbolt.Tx, entityType string) (*bbolt.Bucket, error) {
+	path, err := EntityTypePath(entityType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	bucket := BucketAt(tx, path)
+
+	if bucket == nil {
+		return nil, fmt.Errorf("unknown entity type %s, %s not found", entityType, path)
+	}
+
+	return bucket, nil
+}
+
+// Kinds of EntityField that do not hold a boltz typed value.
+const (
+	EntityFieldList = "list"
+	EntityFieldMap  = "map"
+)
+
+// Entity is a Ziti entity read by DescribeEntity.
+type Entity struct {
+	Path   Path
+	Type   string
+	Id     string
+	Fields []*EntityField
+}
+
+// EntityField is a field of an Entity.
+type EntityField struct {
+	Name string
+
+	// Type is the boltz type of a typed value, EntityFieldList for string lists such as role attributes and links and
+	// EntityFieldMap for nested buckets of values such as tags
+	Type string
+
+	// Value is a native value for typed values, a []string for lists and a map[string]interface{} for maps
+	Value interface{}
+
+	// References holds the entities that string values or list items are the id of, keyed by id
+	References map[string]*EntityReference
+}
+
+// EntityReference is an entity referenced by the id held in a field.
+type EntityReference struct {
+	Path Path
+	Type string
+
+	// Name is the name field of the entity, empty if it has none
+	Name string
+}
+
+// FindEntity returns the path of the entity with the id `id` in EntitiesPath. An error is returned if no entity type
+// holds the id, or if more than one does as it is then ambiguous.
+func (state *State) FindEntity(id string) (Path, error) {
+	var references []*EntityReference
+
+	_ = state.View(func(tx *bbolt.Tx) error {
+		references = findEntities(tx, id)
+		return nil
+	})
+
+	switch len(references) {
+	case 0:
+		return nil, fmt.Errorf("no entity with id %s found in %s", id, EntitiesPath)
+	case 1:
+		return references[0].Path, nil
+	}
+
+	var paths []string
+	for _, reference := range references {
+		paths = append(paths, reference.Path.String())
+	}
+
+	return nil, fmt.Errorf("id %s is ambiguous, use one of the paths: %s", id, strings.Join(paths, ", "))
+}
+
+// findEntity returns the entity with the id `id`, nil if there is none or the id is held by more than one entity type.
+func findEntity(tx *bbolt.Tx, id string) *EntityReference {
+	if references := findEntities(tx, id); len(references) == 1 {
+		return references[0]
+	}
+
+	return nil
+}
+
+// findEntities returns the entities with the id `id`, one for each entity type holding it.
+func findEntities(tx *bbolt.Tx, id string) []*EntityReference {
+	entities := BucketAt(tx, EntitiesPath)
+	if entities == nil || id == "" {
+		return nil
+	}
+
+	var result []*EntityReference
+
+	cursor := entities.Cursor()
+	for entityType, value := cursor.First(); entityType != nil; entityType, value = cursor.Next() {
+		if value != nil {
+			continue
+		}
+
+		entityBucket := entities.Bucket(entityType).Bucket([]byte(id))
+		if entityBucket == nil {
+			continue
+		}
+
+		reference := &EntityReference{Path: EntitiesPath.Child(string(entityType)).Child(id), Type: string(entityType)}
+		if fieldType, name := boltz.GetTypeAndValue(entityBucket.Get([]byte("name"))); fieldType == boltz.TypeString {
+			reference.Name = string(name)
+		}
+
+		result = append(result, reference)
+	}
+
+	return result
+}
+
+// DescribeEntity reads the entity bucket at `path` as a record. Typed values are decoded, nested buckets of string
+// list items, and empty nested buckets, are read as lists and other nested buckets as maps. Every string value and
+// list item that is the id of exactly one other entity in EntitiesPath is resolved to that entity.
+func (state *State) DescribeEntity(path Path) (*Entity, error) {
+	if len(path) == 0 {
+		return nil, errors.New("the root bucket is not an entity")
+	}
+
+	entity := &Entity{Path: path, Id: path[len(path)-1]}
+	if len(path) > 1 {
+		entity.Type = path[len(path)-2]
+	}
+
+	err := state.View(func(tx *bbolt.Tx) error {
+		bucket := BucketAt(tx, path)
+
+		if bucket == nil {
+			return fmt.Errorf("%s not found", path)
+		}
+
+		references := map[string]*EntityReference{}
+		resolve := func(field *EntityField, id string) {
+			reference, found := references[id]
+			if !found {
+				reference = findEntity(tx, id)
+				if reference != nil && reference.Path.Equal(path) {
+					reference = nil
+				}
+				references[id] = reference
+			}
+
+			if reference != nil {
+				if field.References == nil {
+					field.References = map[string]*EntityReference{}
+				}
+				field.References[id] = reference
+			}
+		}
+
+		cursor := bucket.Cursor()
+		for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+			field := &EntityField{Name: string(key)}
+			entity.Fields = append(entity.Fields, field)
+
+			if value != nil {
+				fieldType, fieldValue := boltz.GetTypeAndValue(value)
+				field.Type = TypeToString(fieldType)
+				field.Value = FieldToValue(fieldType, fieldValue)
+
+				if id, ok := field.Value.(string); ok {
+					resolve(field, id)
+				}
+				continue
+			}
+
+			nested := bucket.Bucket(key)
+			if items, isList := listItems(nested); isList {
+				field.Type = EntityFieldList
+				field.Value = items
+
+				for _, item := range items {
+					resolve(field, item)
+				}
+				continue
+			}
+
+			field.Type = EntityFieldMap
+			field.Value = mapValues(nested)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entity, nil
+}
+
+// listItems returns the items of `bucket` if it is a string list or is empty.
+func listItems(bucket *bbolt.Bucket) ([]string, bool) {
+	items := []string{}
+
+	cursor := bucket.Cursor()
+	for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+		entry := NewEntry(key, value)
+
+		if !entry.IsListItem {
+			return nil, false
+		}
+
+		items = append(items, entry.Name)
+	}
+
+	return items, true
+}
+
+// mapValues returns the decoded values of `bucket` keyed by name, nested buckets are read recursively.
+func mapValues(bucket *bbolt.Bucket) map[string]interface{} {
+	values := map[string]interface{}{}
+
+	cursor := bucket.Cursor()
+	for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+		if value == nil {
+			values[string(key)] = mapValues(bucket.Bucket(key))
+		} else {
+			values[string(key)] = FieldToValue(boltz.GetTypeAndValue(value))
+		}
+	}
+
+	return values
+}
+
+// EnterEntity finds the entity with the id `id` with FindEntity and moves the state to it.
+func (state *State) EnterEntity(id string) error {
+	path, err := state.FindEntity(id)
+
+	if err != nil {
+		return err
+	}
+
+	state.SetPath(path)
+
+	return nil
+}
+
+// FollowReference moves the state to the entity referenced by the field `name` of the entity at the current path, as
+// resolved by DescribeEntity. If the field references more than one entity `id` selects which, otherwise it may be
+// empty.
+func (state *State) FollowReference(name string, id string) error {
+	entity, err := state.DescribeEntity(state.Path)
+
+	if err != nil {
+		return err
+	}
+
+	var field *EntityField
+	for _, candidate := range entity.Fields {
+		if candidate.Name == name {
+			field = candidate
+		}
+	}
+
+	if field == nil {
+		return fmt.Errorf("field %s not found in %s", name, state.Path)
+	}
+
+	if len(field.References) == 0 {
+		return fmt.Errorf("%s does not reference an entity", name)
+	}
+
+	var reference *EntityReference
+
+	if id != "" {
+		if reference = field.References[id]; reference == nil {
+			return fmt.Errorf("%s does not reference %s", name, id)
+		}
+	} else if len(field.References) == 1 {
+		for _, only := range field.References {
+			reference = only
+		}
+	} else {
+		return fmt.Errorf("%s references %d entities, select one of: %s", name, len(field.References), strings.Join(field.ReferencedIds(), ", "))
+	}
+
+	state.SetPath(reference.Path)
+
+	return nil
+}
+
+// ReferencedIds returns the ids of the entities referenced by the field in order.
+func (field *EntityField) ReferencedIds() []string {
+	var ids []string
+	for id := range field.References {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
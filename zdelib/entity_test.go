@@ -0,0 +1,359 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"github.com/openziti/storage/boltz"
+	"go.etcd.io/bbolt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// putEntity stores an entity with the id `id` in the `entityType` bucket of EntitiesPath. Fields holding a []string
+// are stored as string lists, a map[string]string as a nested bucket of strings and anything else as a typed value.
+func putEntity(tx *bbolt.Tx, entityType, id string, fields map[string]interface{}) error {
+	if err := createBuckets(tx, EntitiesPath.Child(entityType).Child(id)); err != nil {
+		return err
+	}
+
+	bucket := BucketAt(tx, EntitiesPath.Child(entityType).Child(id))
+
+	for name, value := range fields {
+		switch typed := value.(type) {
+		case []string:
+			list, err := bucket.CreateBucket([]byte(name))
+			if err != nil {
+				return err
+			}
+			for _, item := range typed {
+				if err := list.Put(boltz.PrependFieldType(boltz.TypeString, []byte(item)), []byte{}); err != nil {
+					return err
+				}
+			}
+		case map[string]string:
+			nested, err := bucket.CreateBucket([]byte(name))
+			if err != nil {
+				return err
+			}
+			for key, item := range typed {
+				if err := nested.Put([]byte(key), boltz.PrependFieldType(boltz.TypeString, []byte(item))); err != nil {
+					return err
+				}
+			}
+		default:
+			fieldType := boltz.TypeString
+			switch value.(type) {
+			case bool:
+				fieldType = boltz.TypeBool
+			case int64:
+				fieldType = boltz.TypeInt64
+			}
+
+			encoded, err := EncodeValue(fieldType, value)
+			if err != nil {
+				return err
+			}
+
+			if err := bucket.Put([]byte(name), encoded); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// newEntityTestState returns a State for a db holding three identities, an auth policy they reference, a service
+// referencing two of the identities and an id used by two entity types.
+func newEntityTestState(t *testing.T) *State {
+	return newTestState(t, func(tx *bbolt.Tx) error {
+		entities := []struct {
+			entityType string
+			id         string
+			fields     map[string]interface{}
+		}{
+			{"identities", "id0", map[string]interface{}{
+				"name": "alice", "isAdmin": true, "score": int64(5), "authPolicyId": "ap0",
+				"roleAttributes": []string{"admin", "ops"}, "tags": map[string]string{"env": "prod"},
+			}},
+			{"identities", "id1", map[string]interface{}{
+				"name": "bob", "isAdmin": false, "score": int64(2), "authPolicyId": "gone",
+				"roleAttributes": []string{"ops"}, "tags": map[string]string{"env": "dev"},
+			}},
+			{"identities", "id2", map[string]interface{}{
+				"name": "carol", "isAdmin": false, "score": int64(9), "authPolicyId": "ap0",
+				"roleAttributes": []string{},
+			}},
+			{"authPolicies", "ap0", map[string]interface{}{"name": "default"}},
+			{"services", "svc0", map[string]interface{}{"name": "web", "identityIds": []string{"id0", "id1", "gone"}}},
+			{"configs", "dup", map[string]interface{}{"name": "first"}},
+			{"routers", "dup", map[string]interface{}{"name": "second"}},
+		}
+
+		for _, entity := range entities {
+			if err := putEntity(tx, entity.entityType, entity.id, entity.fields); err != nil {
+				return err
+			}
+		}
+
+		// a reference to the id used by two types cannot be resolved
+		return putEntity(tx, "services", "svc1", map[string]interface{}{"name": "api", "configId": "dup"})
+	})
+}
+
+// describedField returns the field `name` of `entity`, failing the test if there is none.
+func describedField(t *testing.T, entity *Entity, name string) *EntityField {
+	t.Helper()
+
+	for _, field := range entity.Fields {
+		if field.Name == name {
+			return field
+		}
+	}
+
+	t.Fatalf("field %s not found in %s", name, entity.Path)
+	return nil
+}
+
+func TestDescribeEntity(t *testing.T) {
+	state := newEntityTestState(t)
+
+	entity, err := state.DescribeEntity(Path{"ziti", "identities", "id0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entity.Type != "identities" || entity.Id != "id0" {
+		t.Errorf("expected identities id0, got %s %s", entity.Type, entity.Id)
+	}
+
+	var names []string
+	for _, field := range entity.Fields {
+		names = append(names, field.Name)
+	}
+
+	if want := []string{"authPolicyId", "isAdmin", "name", "roleAttributes", "score", "tags"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got fields %v, want %v", names, want)
+	}
+
+	tests := []struct {
+		name      string
+		fieldType string
+		value     interface{}
+	}{
+		{"name", "string", "alice"},
+		{"isAdmin", "bool", true},
+		{"score", "int64", int64(5)},
+		{"roleAttributes", EntityFieldList, []string{"admin", "ops"}},
+		{"tags", EntityFieldMap, map[string]interface{}{"env": "prod"}},
+	}
+
+	for _, test := range tests {
+		field := describedField(t, entity, test.name)
+
+		if field.Type != test.fieldType || !reflect.DeepEqual(field.Value, test.value) {
+			t.Errorf("%s: got (%s) %#v, want (%s) %#v", test.name, field.Type, field.Value, test.fieldType, test.value)
+		}
+	}
+
+	// an empty bucket is an empty list
+	carol, err := state.DescribeEntity(Path{"ziti", "identities", "id2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if field := describedField(t, carol, "roleAttributes"); field.Type != EntityFieldList || !reflect.DeepEqual(field.Value, []string{}) {
+		t.Errorf("expected an empty list, got (%s) %#v", field.Type, field.Value)
+	}
+}
+
+func TestDescribeEntityReferences(t *testing.T) {
+	state := newEntityTestState(t)
+
+	identity, err := state.DescribeEntity(Path{"ziti", "identities", "id0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]*EntityReference{
+		"ap0": {Path: Path{"ziti", "authPolicies", "ap0"}, Type: "authPolicies", Name: "default"},
+	}
+	if got := describedField(t, identity, "authPolicyId").References; !reflect.DeepEqual(got, want) {
+		t.Errorf("authPolicyId: got %v, want %v", got, want)
+	}
+
+	// values that are not the id of an entity are not references
+	for _, name := range []string{"name", "score"} {
+		if references := describedField(t, identity, name).References; references != nil {
+			t.Errorf("%s: expected no references, got %v", name, references)
+		}
+	}
+
+	service, err := state.DescribeEntity(Path{"ziti", "services", "svc0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ids that are not found are left unresolved
+	field := describedField(t, service, "identityIds")
+	if ids := field.ReferencedIds(); !reflect.DeepEqual(ids, []string{"id0", "id1"}) {
+		t.Errorf("identityIds: got references to %v", ids)
+	}
+
+	if name := field.References["id1"].Name; name != "bob" {
+		t.Errorf("expected id1 to resolve to bob, got %s", name)
+	}
+
+	ambiguous, err := state.DescribeEntity(Path{"ziti", "services", "svc1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if references := describedField(t, ambiguous, "configId").References; references != nil {
+		t.Errorf("expected an ambiguous id to be left unresolved, got %v", references)
+	}
+}
+
+func TestDescribeEntityErrors(t *testing.T) {
+	state := newEntityTestState(t)
+
+	if _, err := state.DescribeEntity(nil); err == nil {
+		t.Error("expected an error describing the root")
+	}
+
+	if _, err := state.DescribeEntity(Path{"ziti", "identities", "missing"}); err == nil {
+		t.Error("expected an error describing a missing entity")
+	}
+}
+
+func TestFindEntity(t *testing.T) {
+	state := newEntityTestState(t)
+
+	path, err := state.FindEntity("ap0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (Path{"ziti", "authPolicies", "ap0"}); !path.Equal(want) {
+		t.Errorf("got %s, want %s", path, want)
+	}
+
+	if _, err := state.FindEntity("gone"); err == nil || !strings.Contains(err.Error(), "no entity with id gone") {
+		t.Errorf("expected a missing id to be reported, got %v", err)
+	}
+
+	_, err = state.FindEntity("dup")
+	if err == nil || !strings.Contains(err.Error(), "/ziti/configs/dup, /ziti/routers/dup") {
+		t.Errorf("expected an ambiguous id to list its paths, got %v", err)
+	}
+}
+
+func TestEnterEntity(t *testing.T) {
+	state := newEntityTestState(t)
+
+	if err := state.EnterEntity("id1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (Path{"ziti", "identities", "id1"}); !state.Path.Equal(want) {
+		t.Errorf("got %s, want %s", state.Path, want)
+	}
+
+	for _, id := range []string{"gone", "dup"} {
+		if err := state.EnterEntity(id); err == nil {
+			t.Errorf("%s: expected an error", id)
+		}
+	}
+
+	if want := (Path{"ziti", "identities", "id1"}); !state.Path.Equal(want) {
+		t.Errorf("expected a failed goto not to move, got %s", state.Path)
+	}
+
+	if err := state.Toggle(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(state.Path) != 0 {
+		t.Errorf("expected cd - to return to the root, got %s", state.Path)
+	}
+}
+
+func TestFollowReference(t *testing.T) {
+	tests := []struct {
+		from  Path
+		field string
+		id    string
+		want  Path
+	}{
+		{Path{"ziti", "identities", "id0"}, "authPolicyId", "", Path{"ziti", "authPolicies", "ap0"}},
+		{Path{"ziti", "identities", "id0"}, "authPolicyId", "ap0", Path{"ziti", "authPolicies", "ap0"}},
+		{Path{"ziti", "services", "svc0"}, "identityIds", "id1", Path{"ziti", "identities", "id1"}},
+	}
+
+	for _, test := range tests {
+		state := newEntityTestState(t)
+		state.SetPath(test.from)
+
+		if err := state.FollowReference(test.field, test.id); err != nil {
+			t.Errorf("follow %s %s: %v", test.field, test.id, err)
+			continue
+		}
+
+		if !state.Path.Equal(test.want) {
+			t.Errorf("follow %s %s: got %s, want %s", test.field, test.id, state.Path, test.want)
+		}
+
+		// cd - returns to the entity the reference was followed from
+		if err := state.Toggle(); err != nil {
+			t.Fatal(err)
+		}
+
+		if !state.Path.Equal(test.from) {
+			t.Errorf("follow %s %s: expected cd - to return to %s, got %s", test.field, test.id, test.from, state.Path)
+		}
+	}
+}
+
+func TestFollowReferenceErrors(t *testing.T) {
+	tests := []struct {
+		from  Path
+		field string
+		id    string
+		want  string
+	}{
+		{Path{"ziti", "identities", "id0"}, "missing", "", "field missing not found in /ziti/identities/id0"},
+		{Path{"ziti", "identities", "id0"}, "name", "", "name does not reference an entity"},
+		{Path{"ziti", "identities", "id0"}, "authPolicyId", "ap1", "authPolicyId does not reference ap1"},
+		{Path{"ziti", "identities", "id1"}, "authPolicyId", "", "authPolicyId does not reference an entity"},
+		{Path{"ziti", "services", "svc0"}, "identityIds", "", "identityIds references 2 entities, select one of: id0, id1"},
+		{Path{"ziti", "services", "svc0"}, "identityIds", "gone", "identityIds does not reference gone"},
+		{Path{}, "name", "", "the root bucket is not an entity"},
+	}
+
+	for _, test := range tests {
+		state := newEntityTestState(t)
+		state.SetPath(test.from)
+
+		err := state.FollowReference(test.field, test.id)
+
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("follow %s %s in %s: expected an error containing %q, got %v", test.field, test.id, test.from, test.want, err)
+		}
+
+		if !state.Path.Equal(test.from) {
+			t.Errorf("follow %s %s: expected a failed follow not to move, got %s", test.field, test.id, state.Path)
+		}
+	}
+}
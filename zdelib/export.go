@@ -0,0 +1,242 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/openziti/storage/boltz"
+	"go.etcd.io/bbolt"
+	"io"
+	"math"
+	"time"
+	"unicode/utf8"
+)
+
+// ExportVersion is the version of the document format written by Export.
+const ExportVersion = 1
+
+// Export entry types that are used in addition to the names returned by TypeToString.
+const (
+	ExportTypeBucket = "bucket"
+	ExportTypeRaw    = "raw"
+	ExportTypeEmpty  = "empty"
+)
+
+// ExportFormat selects the layout of the document written by Export.
+type ExportFormat int
+
+const (
+	// ExportJson writes a single JSON document with nested buckets
+	ExportJson ExportFormat = iota
+
+	// ExportNdJson writes one JSON object per line. Each object carries the path of the bucket it belongs to.
+	ExportNdJson
+)
+
+// ExportDocument is the root of a JSON export.
+type ExportDocument struct {
+	Version int            `json:"version"`
+	Path    Path           `json:"path"`
+	Entries []*ExportEntry `json:"entries"`
+}
+
+// ExportEntry is a single key from an exported bucket. Buckets have a type of ExportTypeBucket and hold their keys
+// in Entries. Values that cannot be decoded without loss have a type of ExportTypeRaw and hold their bytes,
+// including the boltz type prefix, in Raw. Keys that are not valid UTF-8 are written to KeyRaw instead of Key. Keys
+// that are boltz typed strings, as used by string list buckets, have a KeyType of "string".
+type ExportEntry struct {
+	Key     string          `json:"key,omitempty"`
+	KeyRaw  []byte          `json:"keyRaw,omitempty"`
+	KeyType string          `json:"keyType,omitempty"`
+	Type    string          `json:"type"`
+	Value   json.RawMessage `json:"value,omitempty"`
+	Raw     []byte          `json:"raw,omitempty"`
+	Entries []*ExportEntry  `json:"entries,omitempty"`
+}
+
+// ExportRecord is a single line of an ExportNdJson export.
+type ExportRecord struct {
+	Version int  `json:"version"`
+	Path    Path `json:"path"`
+	*ExportEntry
+}
+
+// Export writes the bucket at the state's current path, and every bucket nested within it, to `out`.
+func (state *State) Export(out io.Writer, format ExportFormat) error {
+	return state.View(func(tx *bbolt.Tx) error {
+		bucket := state.CurrentBucket(tx)
+
+		if bucket == nil {
+			return errors.New("invalid bucket")
+		}
+
+		return ExportBucket(bucket, state.Path, out, format)
+	})
+}
+
+// ExportBucket writes `bucket`, which is located at `path`, and every bucket nested within it to `out`.
+func ExportBucket(bucket *bbolt.Bucket, path Path, out io.Writer, format ExportFormat) error {
+	if path == nil {
+		path = Path{}
+	}
+
+	if format == ExportNdJson {
+		encoder := json.NewEncoder(out)
+		return exportRecords(bucket, path, func(record *ExportRecord) error {
+			return encoder.Encode(record)
+		})
+	}
+
+	entries, err := exportEntries(bucket)
+
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(&ExportDocument{
+		Version: ExportVersion,
+		Path:    path,
+		Entries: entries,
+	})
+}
+
+// exportEntries returns the ExportEntry values for every key in `bucket`, recursing into nested buckets.
+func exportEntries(bucket *bbolt.Bucket) ([]*ExportEntry, error) {
+	entries := []*ExportEntry{}
+
+	cursor := bucket.Cursor()
+	for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+		entry := NewExportEntry(key, value)
+
+		if value == nil {
+			childEntries, err := exportEntries(bucket.Bucket(key))
+			if err != nil {
+				return nil, err
+			}
+			entry.Entries = childEntries
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// exportRecords calls `emit` with an ExportRecord for every key in `bucket`, recursing into nested buckets after
+// the record for the bucket itself has been emitted.
+func exportRecords(bucket *bbolt.Bucket, path Path, emit func(record *ExportRecord) error) error {
+	cursor := bucket.Cursor()
+	for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+		if err := emit(&ExportRecord{Version: ExportVersion, Path: path, ExportEntry: NewExportEntry(key, value)}); err != nil {
+			return err
+		}
+
+		if value == nil {
+			childPath := path.Child(string(key))
+			if err := exportRecords(bucket.Bucket(key), childPath, emit); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// NewExportEntry returns an ExportEntry for a single key and value as returned from a bbolt.Cursor. A nil value
+// denotes a bucket, the returned entry will not have its nested entries populated.
+func NewExportEntry(key, value []byte) *ExportEntry {
+	entry := &ExportEntry{}
+
+	if len(key) > 1 && boltz.FieldType(key[0]) == boltz.TypeString && utf8.Valid(key[1:]) {
+		entry.KeyType = TypeToString(boltz.TypeString)
+		entry.Key = string(key[1:])
+	} else if utf8.Valid(key) {
+		entry.Key = string(key)
+	} else {
+		entry.KeyRaw = append([]byte{}, key...)
+	}
+
+	switch {
+	case value == nil:
+		entry.Type = ExportTypeBucket
+	case len(value) == 0:
+		entry.Type = ExportTypeEmpty
+	default:
+		fieldType, fieldValue := boltz.GetTypeAndValue(value)
+
+		if exportValue, ok := losslessValue(fieldType, fieldValue, value); ok {
+			entry.Type = TypeToString(fieldType)
+			entry.Value = exportValue
+		} else {
+			entry.Type = ExportTypeRaw
+			entry.Raw = append([]byte{}, value...)
+		}
+	}
+
+	return entry
+}
+
+// losslessValue returns the JSON representation of a typed value if it can be encoded back into exactly the
+// original bytes.
+func losslessValue(fieldType boltz.FieldType, fieldValue []byte, original []byte) (json.RawMessage, bool) {
+	value := FieldToValue(fieldType, fieldValue)
+
+	switch v := value.(type) {
+	case string:
+		if !utf8.ValidString(v) {
+			return nil, false
+		}
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, false
+		}
+	case time.Time:
+		value = v.Format(time.RFC3339Nano)
+	case nil:
+		if fieldType != boltz.TypeNil {
+			return nil, false
+		}
+	}
+
+	exportValue, err := json.Marshal(value)
+	if err != nil {
+		return nil, false
+	}
+
+	encoded, err := EncodeValue(fieldType, decodeJsonValue(exportValue))
+	if err != nil || !bytes.Equal(encoded, original) {
+		return nil, false
+	}
+
+	return exportValue, true
+}
+
+// decodeJsonValue decodes a JSON value, preserving numbers as json.Number. Invalid JSON decodes to nil.
+func decodeJsonValue(raw json.RawMessage) interface{} {
+	var value interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&value); err != nil {
+		return nil
+	}
+
+	return value
+}
@@ -0,0 +1,42 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"github.com/openziti/storage/boltz"
+	"reflect"
+	"testing"
+)
+
+func TestNewExportEntry(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   []byte
+		value []byte
+		want  ExportEntry
+	}{
+		{"bucket", []byte("b"), nil, ExportEntry{Key: "b", Type: ExportTypeBucket}},
+		{"empty", []byte("e"), []byte{}, ExportEntry{Key: "e", Type: ExportTypeEmpty}},
+		{"string", []byte("s"), []byte{byte(boltz.TypeString), 'x'}, ExportEntry{Key: "s", Type: "string", Value: []byte(`"x"`)}},
+		{"typed key", []byte{byte(boltz.TypeString), 'r'}, nil, ExportEntry{Key: "r", KeyType: "string", Type: ExportTypeBucket}},
+		{"raw key", []byte{0xff}, []byte{byte(boltz.TypeBool), 1}, ExportEntry{KeyRaw: []byte{0xff}, Type: "bool", Value: []byte("true")}},
+		{"raw value", []byte("v"), []byte{0x7f}, ExportEntry{Key: "v", Type: ExportTypeRaw, Raw: []byte{0x7f}}},
+	}
+
+	for _, test := range tests {
+		if got := NewExportEntry(test.key, test.value); !reflect.DeepEqual(*got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, *got, test.want)
+		}
+	}
+}
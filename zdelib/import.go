@@ -0,0 +1,295 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/openziti/storage/boltz"
+	"go.etcd.io/bbolt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ImportFile builds a bbolt database file at `path` from a document read from `in` with Import. The database is built
+// in a temporary file in the same directory and renamed to `path` only once the import succeeds, so an existing file
+// is never lost to a failed import. If the file exists and `overwrite` is false an error wrapping os.ErrExist is
+// returned. An existing file is only replaced while holding its lock, if that cannot be acquired within `timeout` a
+// *LockError naming the processes holding it is returned.
+func ImportFile(path string, in io.Reader, overwrite bool, timeout time.Duration) error {
+	if _, err := os.Stat(path); err == nil {
+		if !overwrite {
+			return fmt.Errorf("%w: %s", os.ErrExist, path)
+		}
+
+		existing, err := lockExisting(path, timeout)
+		if err != nil {
+			return err
+		}
+
+		if existing != nil {
+			defer func() { _ = existing.Close() }()
+		}
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".import-*")
+	if err != nil {
+		return err
+	}
+
+	tempPath := temp.Name()
+	if err := temp.Close(); err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+
+	db, err := bbolt.Open(tempPath, 0600, &bbolt.Options{Timeout: timeout})
+	if err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+
+	if err := Import(db, in); err != nil {
+		_ = db.Close()
+		_ = os.Remove(tempPath)
+		return err
+	}
+
+	if err := db.Close(); err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+
+	return nil
+}
+
+// lockExisting opens the existing db at `path` to hold its lock, so it is not replaced while another process, such as a
+// running controller, has it open. If the file is not a bbolt database no process can be using it as one and nil is
+// returned without an error.
+func lockExisting(path string, timeout time.Duration) (*bbolt.DB, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: timeout})
+
+	if err == bbolt.ErrTimeout {
+		holders, _ := FindLockHolders(path)
+		return nil, &LockError{Path: path, Holders: holders, Err: err}
+	}
+
+	if err == bbolt.ErrInvalid || err == bbolt.ErrVersionMismatch || err == bbolt.ErrChecksum {
+		return nil, nil
+	}
+
+	return db, err
+}
+
+// Import reads a document written by Export from `in` and stores its buckets and values in `db` within a single
+// transaction. Both ExportJson and ExportNdJson documents are supported. Buckets along the exported path are created
+// as required.
+func Import(db *bbolt.DB, in io.Reader) error {
+	decoder := json.NewDecoder(in)
+
+	var first json.RawMessage
+	if err := decoder.Decode(&first); err != nil {
+		return fmt.Errorf("invalid export document: %w", err)
+	}
+
+	probe := &struct {
+		Type    string          `json:"type"`
+		Entries json.RawMessage `json:"entries"`
+	}{}
+
+	if err := json.Unmarshal(first, probe); err != nil {
+		return fmt.Errorf("invalid export document: %w", err)
+	}
+
+	return db.Update(func(tx *bbolt.Tx) error {
+		if probe.Type == "" {
+			doc := &ExportDocument{}
+			if err := json.Unmarshal(first, doc); err != nil {
+				return fmt.Errorf("invalid export document: %w", err)
+			}
+
+			return importDocument(tx, doc)
+		}
+
+		for raw := first; ; {
+			record := &ExportRecord{}
+			if err := json.Unmarshal(raw, record); err != nil {
+				return fmt.Errorf("invalid export record: %w", err)
+			}
+
+			if err := importRecord(tx, record); err != nil {
+				return err
+			}
+
+			raw = nil
+			if err := decoder.Decode(&raw); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("invalid export record: %w", err)
+			}
+		}
+	})
+}
+
+// importDocument stores the entries of an ExportJson document.
+func importDocument(tx *bbolt.Tx, doc *ExportDocument) error {
+	if doc.Version != ExportVersion {
+		return fmt.Errorf("unsupported export version: %d", doc.Version)
+	}
+
+	if len(doc.Path) == 0 {
+		return importRootEntries(tx, doc.Entries)
+	}
+
+	bucket, err := createPath(tx, doc.Path)
+	if err != nil {
+		return err
+	}
+
+	return importEntries(bucket, doc.Path, doc.Entries)
+}
+
+// importRootEntries stores entries at the root of the db, where only buckets are allowed.
+func importRootEntries(tx *bbolt.Tx, entries []*ExportEntry) error {
+	for _, entry := range entries {
+		key := entry.KeyBytes()
+
+		if entry.Type != ExportTypeBucket {
+			return fmt.Errorf("%s: only buckets may be stored at the root of a db", string(key))
+		}
+
+		bucket, err := tx.CreateBucketIfNotExists(key)
+		if err != nil {
+			return fmt.Errorf("%s: %w", string(key), err)
+		}
+
+		if err := importEntries(bucket, []string{string(key)}, entry.Entries); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// importEntries stores entries, and the entries of nested buckets, in `bucket`.
+func importEntries(bucket *bbolt.Bucket, path Path, entries []*ExportEntry) error {
+	for _, entry := range entries {
+		key := entry.KeyBytes()
+		entryPath := path.Child(string(key))
+
+		if entry.Type == ExportTypeBucket {
+			child, err := bucket.CreateBucketIfNotExists(key)
+			if err != nil {
+				return fmt.Errorf("%s: %w", entryPath, err)
+			}
+
+			if err := importEntries(child, entryPath, entry.Entries); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, err := entry.ValueBytes()
+		if err != nil {
+			return fmt.Errorf("%s: %w", entryPath, err)
+		}
+
+		if err := bucket.Put(key, value); err != nil {
+			return fmt.Errorf("%s: %w", entryPath, err)
+		}
+	}
+
+	return nil
+}
+
+// importRecord stores a single ExportNdJson record.
+func importRecord(tx *bbolt.Tx, record *ExportRecord) error {
+	if record.Version != ExportVersion {
+		return fmt.Errorf("unsupported export version: %d", record.Version)
+	}
+
+	if record.ExportEntry == nil {
+		return errors.New("invalid export record: missing entry")
+	}
+
+	if len(record.Path) == 0 {
+		return importRootEntries(tx, []*ExportEntry{record.ExportEntry})
+	}
+
+	bucket, err := createPath(tx, record.Path)
+	if err != nil {
+		return err
+	}
+
+	return importEntries(bucket, record.Path, []*ExportEntry{record.ExportEntry})
+}
+
+// createPath returns the bucket at `path`, creating buckets as required.
+func createPath(tx *bbolt.Tx, path Path) (*bbolt.Bucket, error) {
+	bucket, err := tx.CreateBucketIfNotExists([]byte(path[0]))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path[0:1], err)
+	}
+
+	for i, name := range path[1:] {
+		if bucket, err = bucket.CreateBucketIfNotExists([]byte(name)); err != nil {
+			return nil, fmt.Errorf("%s: %w", path[0:i+2], err)
+		}
+	}
+
+	return bucket, nil
+}
+
+// KeyBytes returns the key of the entry as it is stored in bbolt.
+func (entry *ExportEntry) KeyBytes() []byte {
+	if entry.KeyRaw != nil {
+		return entry.KeyRaw
+	}
+
+	if entry.KeyType != "" {
+		return boltz.PrependFieldType(boltz.TypeString, []byte(entry.Key))
+	}
+
+	return []byte(entry.Key)
+}
+
+// ValueBytes returns the value of a non-bucket entry as it is stored in bbolt, including the boltz type prefix.
+func (entry *ExportEntry) ValueBytes() ([]byte, error) {
+	switch entry.Type {
+	case ExportTypeBucket:
+		return nil, errors.New("buckets do not have a value")
+	case ExportTypeRaw:
+		if entry.Raw == nil {
+			return []byte{}, nil
+		}
+		return entry.Raw, nil
+	case ExportTypeEmpty:
+		return []byte{}, nil
+	}
+
+	fieldType, err := ParseType(entry.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	return EncodeValue(fieldType, decodeJsonValue(entry.Value))
+}
@@ -0,0 +1,277 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/openziti/storage/boltz"
+	"go.etcd.io/bbolt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testImportDoc = `{"version":1,"path":["ziti"],"entries":[{"key":"name","type":"string","value":"bob"}]}`
+
+func TestImportFileKeepsTargetOnFailure(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "keep.db")
+	original := []byte("not replaced")
+
+	if err := os.WriteFile(target, original, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ImportFile(target, strings.NewReader("{bad"), true, time.Second); err == nil {
+		t.Fatal("expected an error importing a malformed document")
+	}
+
+	contents, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatalf("target was removed by a failed import: %v", err)
+	}
+
+	if !bytes.Equal(contents, original) {
+		t.Errorf("target was modified by a failed import")
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(target))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("expected the temporary file to be removed, found %d files", len(entries))
+	}
+}
+
+func TestImportFileOverwrite(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "new.db")
+
+	if err := os.WriteFile(target, []byte("existing"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ImportFile(target, strings.NewReader(testImportDoc), false, time.Second); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected an error importing over an existing file without overwrite, got %v", err)
+	}
+
+	if err := ImportFile(target, strings.NewReader(testImportDoc), true, time.Second); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+
+	state, err := NewStateWithOptions(target, &OpenOptions{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		t.Fatalf("could not open imported db: %v", err)
+	}
+	defer state.Done()
+
+	if err := state.EnterPath("/ziti"); err != nil {
+		t.Fatal(err)
+	}
+
+	if value := state.GetValue("name"); value != "bob" {
+		t.Errorf("expected name to be bob, got %s", value)
+	}
+}
+
+func TestImportFileLockedTarget(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "locked.db")
+
+	db, err := bbolt.Open(target, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	before, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ImportFile(target, strings.NewReader(testImportDoc), true, 50*time.Millisecond)
+
+	var lockErr *LockError
+	if !errors.As(err, &lockErr) {
+		t.Fatalf("expected a lock error replacing a db that is open, got %v", err)
+	}
+
+	after, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !os.SameFile(before, after) {
+		t.Error("a db that is open was replaced")
+	}
+}
+
+// populateExportTest stores a value of every kind Export handles in `tx`.
+func populateExportTest(t *testing.T) func(tx *bbolt.Tx) error {
+	encode := func(fieldType boltz.FieldType, value interface{}) []byte {
+		encoded, err := EncodeValue(fieldType, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encoded
+	}
+
+	nan := make([]byte, 9)
+	nan[0] = byte(boltz.TypeFloat64)
+	binary.LittleEndian.PutUint64(nan[1:], math.Float64bits(math.NaN()))
+
+	return func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucket([]byte("ziti"))
+		if err != nil {
+			return err
+		}
+
+		if bucket, err = bucket.CreateBucket([]byte("identities")); err != nil {
+			return err
+		}
+
+		if bucket, err = bucket.CreateBucket([]byte("id0")); err != nil {
+			return err
+		}
+
+		values := map[string][]byte{
+			"name":      encode(boltz.TypeString, "bob \"the\" \\ builder"),
+			"count":     encode(boltz.TypeInt64, int64(-42)),
+			"small":     encode(boltz.TypeInt32, int32(7)),
+			"ratio":     encode(boltz.TypeFloat64, 0.25),
+			"isAdmin":   encode(boltz.TypeBool, true),
+			"createdAt": encode(boltz.TypeTime, time.Date(2022, 1, 2, 3, 4, 5, 6, time.UTC)),
+			"deleted":   encode(boltz.TypeNil, nil),
+			"nan":       nan,
+			"binary":    {0x7f, 0x00, 0xff},
+			"badString": {byte(boltz.TypeString), 0xff, 0xfe},
+			"empty":     {},
+		}
+
+		for key, value := range values {
+			if err := bucket.Put([]byte(key), value); err != nil {
+				return err
+			}
+		}
+
+		if err := bucket.Put([]byte{0xff, 0x00, 'k'}, encode(boltz.TypeString, "raw key")); err != nil {
+			return err
+		}
+
+		roles, err := bucket.CreateBucket([]byte("roleAttributes"))
+		if err != nil {
+			return err
+		}
+
+		for _, role := range []string{"admin", "edge"} {
+			if err := roles.Put(encode(boltz.TypeString, role), nil); err != nil {
+				return err
+			}
+		}
+
+		_, err = bucket.CreateBucket([]byte("emptyBucket"))
+		return err
+	}
+}
+
+// dumpDb returns every key of every bucket in `db` mapped to its value, with buckets mapped to "bucket".
+func dumpDb(t *testing.T, db *bbolt.DB) map[string]string {
+	t.Helper()
+
+	result := map[string]string{}
+
+	var dump func(prefix string, bucket *bbolt.Bucket)
+	dump = func(prefix string, bucket *bbolt.Bucket) {
+		cursor := bucket.Cursor()
+		for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+			name := fmt.Sprintf("%s/%x", prefix, key)
+			if value == nil {
+				result[name] = "bucket"
+				dump(name, bucket.Bucket(key))
+			} else {
+				result[name] = fmt.Sprintf("%x", value)
+			}
+		}
+	}
+
+	err := db.View(func(tx *bbolt.Tx) error {
+		return tx.ForEach(func(name []byte, bucket *bbolt.Bucket) error {
+			result[fmt.Sprintf("%x", name)] = "bucket"
+			dump(fmt.Sprintf("%x", name), bucket)
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	state := newTestState(t, populateExportTest(t))
+	want := dumpDb(t, state.DB)
+
+	for _, path := range []Path{{}, {"ziti", "identities"}} {
+		for name, format := range map[string]ExportFormat{"json": ExportJson, "ndjson": ExportNdJson} {
+			state.SetPath(path)
+
+			exported := &bytes.Buffer{}
+			if err := state.Export(exported, format); err != nil {
+				t.Fatalf("%s %s: export failed: %v", name, path, err)
+			}
+
+			target := filepath.Join(t.TempDir(), "imported.db")
+			if err := ImportFile(target, bytes.NewReader(exported.Bytes()), false, time.Second); err != nil {
+				t.Fatalf("%s %s: import failed: %v\n%s", name, path, err, exported)
+			}
+
+			imported, err := NewStateWithOptions(target, &OpenOptions{Timeout: time.Second, ReadOnly: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := dumpDb(t, imported.DB); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s %s: imported db does not match\ngot:  %v\nwant: %v", name, path, got, want)
+			}
+
+			imported.Done()
+		}
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	docs := map[string]string{
+		"malformed":          `{bad`,
+		"version":            `{"version":2,"path":[],"entries":[]}`,
+		"value at root":      `{"version":1,"path":[],"entries":[{"key":"a","type":"string","value":"x"}]}`,
+		"invalid value":      `{"version":1,"path":["a"],"entries":[{"key":"n","type":"int64","value":"x"}]}`,
+		"record version":     `{"version":2,"path":["a"],"key":"n","type":"string","value":"x"}`,
+		"malformed record":   `{"version":1,"path":["a"],"key":"n","type":"string","value":"x"}` + "\n{bad",
+		"record missing key": `{"version":1,"path":["a"],"type":"string"}` + "\n" + `{"version":1,"path":["a"]}`,
+	}
+
+	for name, doc := range docs {
+		target := filepath.Join(t.TempDir(), "imported.db")
+		if err := ImportFile(target, bytes.NewReader([]byte(doc)), false, time.Second); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"fmt"
+	"go.etcd.io/bbolt"
+)
+
+// Iterator steps through the keys of a single bucket in the range selected by its RangeOptions. Unless the state is
+// pinned, an Iterator owns a read transaction which is held open until Close is called, so it sees a consistent view
+// of the bucket. Writes made with the same State while an Iterator is open may block until it is closed.
+//
+//	iterator, err := state.Iterate(path, &zdelib.RangeOptions{Prefix: []byte("abc")})
+//	if err != nil {
+//		return err
+//	}
+//	defer iterator.Close()
+//
+//	for iterator.Next() {
+//		entry := iterator.Entry()
+//	}
+type Iterator struct {
+	tx      *bbolt.Tx
+	ownsTx  bool
+	cursor  *bbolt.Cursor
+	options RangeOptions
+	lower   *bound
+	upper   *bound
+	key     []byte
+	value   []byte
+	started bool
+	done    bool
+}
+
+// Iterate returns an Iterator over the keys of the bucket at `path` selected by `options`, which may be nil to visit
+// every key in order. If the state is pinned the Iterator uses the pinned transaction.
+func (state *State) Iterate(path Path, options *RangeOptions) (*Iterator, error) {
+	tx := state.pinned
+	ownsTx := tx == nil
+
+	if ownsTx {
+		var err error
+		if tx, err = state.DB.Begin(false); err != nil {
+			return nil, err
+		}
+	}
+
+	bucket := BucketAt(tx, path)
+
+	if bucket == nil {
+		if ownsTx {
+			_ = tx.Rollback()
+		}
+		return nil, fmt.Errorf("bucket not found: %s", path)
+	}
+
+	iterator := &Iterator{
+		tx:     tx,
+		ownsTx: ownsTx,
+		cursor: bucket.Cursor(),
+	}
+
+	if options != nil {
+		iterator.options = *options
+	}
+	iterator.reset()
+
+	return iterator, nil
+}
+
+// reset recalculates the bounds of the range and moves back before the first key.
+func (iterator *Iterator) reset() {
+	iterator.lower = iterator.options.lower()
+	iterator.upper = iterator.options.upper()
+	iterator.key = nil
+	iterator.value = nil
+	iterator.started = false
+	iterator.done = iterator.tx == nil
+}
+
+// Next moves to the next key in the range and returns true, or returns false once the range is exhausted.
+func (iterator *Iterator) Next() bool {
+	if iterator.done {
+		return false
+	}
+
+	var key, value []byte
+
+	switch {
+	case !iterator.started && iterator.options.Reverse:
+		key, value = iterator.last()
+	case !iterator.started:
+		key, value = iterator.first()
+	case iterator.options.Reverse:
+		key, value = iterator.cursor.Prev()
+	default:
+		key, value = iterator.cursor.Next()
+	}
+	iterator.started = true
+
+	if key == nil || (iterator.lower != nil && iterator.lower.below(key)) || (iterator.upper != nil && iterator.upper.above(key)) {
+		iterator.done = true
+		iterator.key = nil
+		iterator.value = nil
+		return false
+	}
+
+	iterator.key = key
+	iterator.value = value
+
+	return true
+}
+
+// first positions the cursor at the lowest key in range.
+func (iterator *Iterator) first() ([]byte, []byte) {
+	if iterator.lower == nil {
+		return iterator.cursor.First()
+	}
+
+	key, value := iterator.cursor.Seek(iterator.lower.key)
+	if key != nil && iterator.lower.below(key) {
+		return iterator.cursor.Next()
+	}
+
+	return key, value
+}
+
+// last positions the cursor at the highest key in range.
+func (iterator *Iterator) last() ([]byte, []byte) {
+	if iterator.upper == nil {
+		return iterator.cursor.Last()
+	}
+
+	key, value := iterator.cursor.Seek(iterator.upper.key)
+	if key == nil {
+		return iterator.cursor.Last()
+	}
+
+	if iterator.upper.above(key) {
+		return iterator.cursor.Prev()
+	}
+
+	return key, value
+}
+
+// Seek moves the iterator so the next call to Next returns the first key in range at or after `key`, or at or before
+// it when iterating in reverse.
+func (iterator *Iterator) Seek(key []byte) {
+	iterator.options.Start = append([]byte{}, key...)
+	iterator.reset()
+}
+
+// Key returns the raw key the iterator is at. The returned slice is only valid until Next, Seek or Close is called.
+func (iterator *Iterator) Key() []byte {
+	return iterator.key
+}
+
+// Entry returns the Entry for the key the iterator is at.
+func (iterator *Iterator) Entry() Entry {
+	return NewEntry(iterator.key, iterator.value)
+}
+
+// Bucket returns the bucket the iterator is at if the key is a bucket, otherwise nil. The bucket is only valid until
+// Close is called.
+func (iterator *Iterator) Bucket() *bbolt.Bucket {
+	if iterator.key == nil || iterator.value != nil {
+		return nil
+	}
+
+	return iterator.cursor.Bucket().Bucket(iterator.key)
+}
+
+// Close releases the iterator's read transaction, unless it is the state's pinned transaction. It is safe to call
+// Close more than once.
+func (iterator *Iterator) Close() error {
+	if iterator.tx == nil {
+		return nil
+	}
+
+	var err error
+	if iterator.ownsTx {
+		err = iterator.tx.Rollback()
+	}
+	iterator.tx = nil
+	iterator.done = true
+
+	return err
+}
@@ -0,0 +1,85 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LockHolder is a local process that holds, or may hold, the lock on a database file.
+type LockHolder struct {
+	Pid int
+
+	// Command is the command line of the process, arguments separated by spaces
+	Command string
+
+	// Started is when the process was started, zero if it is unknown
+	Started time.Time
+
+	// Exclusive is true if the process holds the lock for writing, which blocks every other process
+	Exclusive bool
+
+	// Confirmed is true if the lock was found in the kernel's lock table, otherwise the process only has the file open
+	Confirmed bool
+}
+
+func (holder *LockHolder) String() string {
+	result := fmt.Sprintf("pid %d", holder.Pid)
+
+	if holder.Command != "" {
+		result += fmt.Sprintf(" (%s)", holder.Command)
+	}
+
+	if !holder.Started.IsZero() {
+		result += fmt.Sprintf(" started %s", holder.Started.Format(time.RFC3339))
+	}
+
+	if !holder.Confirmed {
+		result += " has the file open"
+	} else if holder.Exclusive {
+		result += " holds the write lock"
+	} else {
+		result += " holds a read lock"
+	}
+
+	return result
+}
+
+// LockError is returned when the lock on a database file could not be acquired before the timeout. Holders lists the
+// local processes found holding the lock, it is empty if none could be identified, e.g. when the lock is held from
+// another host or container or on platforms without /proc.
+type LockError struct {
+	Path    string
+	Holders []*LockHolder
+	Err     error
+}
+
+func (e *LockError) Error() string {
+	holders := make([]string, 0, len(e.Holders))
+	for _, holder := range e.Holders {
+		holders = append(holders, holder.String())
+	}
+
+	if len(holders) == 0 {
+		return fmt.Sprintf("could not acquire the lock on %s: %v", e.Path, e.Err)
+	}
+
+	return fmt.Sprintf("could not acquire the lock on %s, %s: %v", e.Path, strings.Join(holders, ", "), e.Err)
+}
+
+func (e *LockError) Unwrap() error {
+	return e.Err
+}
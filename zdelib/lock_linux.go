@@ -0,0 +1,212 @@
+//go:build linux
+
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// clockTicks is the kernel's USER_HZ, the unit of process start times in /proc/<pid>/stat. It is 100 on every
+// architecture linux supports and cannot be read without cgo.
+const clockTicks = 100
+
+// FindLockHolders returns the local processes holding a lock on the file at `path`, found in /proc/locks, followed by
+// any other processes that have the file open. Processes that belong to other users can only be found when running as
+// root or as that user.
+func FindLockHolders(path string) ([]*LockHolder, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, nil
+	}
+
+	locks, err := readLocks(stat)
+	if err != nil {
+		return nil, err
+	}
+
+	holders := map[int]*LockHolder{}
+	for pid, exclusive := range locks {
+		holders[pid] = &LockHolder{Pid: pid, Exclusive: exclusive, Confirmed: true}
+	}
+
+	for _, pid := range openedBy(info) {
+		if _, found := holders[pid]; !found {
+			holders[pid] = &LockHolder{Pid: pid}
+		}
+	}
+
+	var result []*LockHolder
+	for _, holder := range holders {
+		if holder.Pid == os.Getpid() {
+			continue
+		}
+		holder.Command = processCommand(holder.Pid)
+		holder.Started = processStarted(holder.Pid)
+		result = append(result, holder)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Confirmed != result[j].Confirmed {
+			return result[i].Confirmed
+		}
+		return result[i].Pid < result[j].Pid
+	})
+
+	return result, nil
+}
+
+// readLocks returns the pid of each process with an flock on the file described by `stat` from /proc/locks and
+// whether the lock is exclusive.
+func readLocks(stat *syscall.Stat_t) (map[int]bool, error) {
+	file, err := os.Open("/proc/locks")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	return parseLocks(file, lockTarget(uint64(stat.Dev), stat.Ino))
+}
+
+// lockTarget returns the `major:minor:inode` form /proc/locks uses to identify a file, with the device major and minor
+// numbers in hex.
+func lockTarget(dev uint64, ino uint64) string {
+	major := (dev>>8)&0xfff | (dev>>32)&^0xfff
+	minor := dev&0xff | (dev>>12)&^0xff
+	return fmt.Sprintf("%02x:%02x:%d", major, minor, ino)
+}
+
+// parseLocks reads the contents of /proc/locks from `in` and returns the pid of each process with an flock on
+// `target`, see lockTarget, and whether the lock is exclusive. Lines have the form
+// `1: FLOCK ADVISORY WRITE 1234 fd:01:5678 0 EOF`.
+func parseLocks(in io.Reader, target string) (map[int]bool, error) {
+	result := map[int]bool{}
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		// blocked waiters are listed as "1: -> FLOCK ..." and do not hold the lock
+		if len(fields) < 6 || fields[1] != "FLOCK" || fields[5] != target {
+			continue
+		}
+
+		pid, err := strconv.Atoi(fields[4])
+		if err != nil || pid <= 0 {
+			continue
+		}
+
+		result[pid] = result[pid] || fields[3] == "WRITE"
+	}
+
+	return result, scanner.Err()
+}
+
+// openedBy returns the pid of each process with a file descriptor open on the file described by `info`.
+func openedBy(info os.FileInfo) []int {
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+
+	var result []int
+	seen := map[int]bool{}
+
+	for _, fd := range fds {
+		pid, err := strconv.Atoi(strings.Split(fd, "/")[2])
+		if err != nil || seen[pid] {
+			continue
+		}
+
+		if target, err := os.Stat(fd); err == nil && os.SameFile(info, target) {
+			seen[pid] = true
+			result = append(result, pid)
+		}
+	}
+
+	return result
+}
+
+// processCommand returns the command line of the process `pid`, empty if it cannot be read.
+func processCommand(pid int) string {
+	cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(bytes.ReplaceAll(cmdline, []byte{0}, []byte{' '})))
+}
+
+// processStarted returns when the process `pid` was started, the zero time if it cannot be read.
+func processStarted(pid int) time.Time {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return time.Time{}
+	}
+
+	// the command name in parentheses may contain spaces, the start time is the 20th field after it
+	end := bytes.LastIndexByte(stat, ')')
+	if end < 0 {
+		return time.Time{}
+	}
+
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) < 20 {
+		return time.Time{}
+	}
+
+	ticks, err := strconv.ParseInt(fields[19], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+
+	boot := bootTime()
+	if boot.IsZero() {
+		return time.Time{}
+	}
+
+	return boot.Add(time.Duration(ticks) * time.Second / clockTicks)
+}
+
+// bootTime returns when the system was booted from the btime line of /proc/stat, the zero time if it cannot be read.
+func bootTime() time.Time {
+	stat, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}
+	}
+
+	for _, line := range strings.Split(string(stat), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "btime" {
+			if seconds, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+				return time.Unix(seconds, 0)
+			}
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,108 @@
+//go:build linux
+
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestLockTarget(t *testing.T) {
+	tests := []struct {
+		dev  uint64
+		ino  uint64
+		want string
+	}{
+		{0x0801, 5678, "08:01:5678"},
+		{0xfd01, 12, "fd:01:12"},
+		{0x00, 1, "00:00:1"},
+		{0x10301, 42, "103:01:42"},
+		{0x100800, 7, "08:100:7"},
+	}
+
+	for _, test := range tests {
+		if got := lockTarget(test.dev, test.ino); got != test.want {
+			t.Errorf("lockTarget(%#x, %d): got %s, want %s", test.dev, test.ino, got, test.want)
+		}
+	}
+}
+
+func TestParseLocks(t *testing.T) {
+	locks := strings.Join([]string{
+		"1: FLOCK  ADVISORY  WRITE 1234 fd:01:5678 0 EOF",
+		"1: -> FLOCK  ADVISORY  WRITE 999 fd:01:5678 0 EOF",
+		"2: FLOCK  ADVISORY  READ  2000 fd:01:5678 0 EOF",
+		"2: FLOCK  ADVISORY  READ  2000 fd:01:5678 0 EOF",
+		"3: POSIX  ADVISORY  WRITE 3000 fd:01:5678 0 EOF",
+		"4: FLOCK  ADVISORY  WRITE 4000 fd:01:9999 0 EOF",
+		"5: OFDLCK ADVISORY  READ  -1 fd:01:5678 0 EOF",
+		"garbage",
+		"",
+	}, "\n")
+
+	got, err := parseLocks(strings.NewReader(locks), "fd:01:5678")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := map[int]bool{1234: true, 2000: false}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestReadLocks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "locked.db")
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		t.Skipf("flock not supported: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locks, err := readLocks(info.Sys().(*syscall.Stat_t))
+	if err != nil {
+		t.Skipf("/proc/locks not readable: %v", err)
+	}
+
+	if exclusive, found := locks[os.Getpid()]; !found || !exclusive {
+		t.Errorf("expected this process to hold the write lock, got %v", locks)
+	}
+
+	// the current process is left out of the holders reported
+	holders, err := FindLockHolders(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, holder := range holders {
+		if holder.Pid == os.Getpid() {
+			t.Errorf("expected the current process to be excluded, got %s", holder)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLockHolderString(t *testing.T) {
+	started := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		holder *LockHolder
+		want   string
+	}{
+		{&LockHolder{Pid: 1, Command: "ziti controller run", Started: started, Exclusive: true, Confirmed: true},
+			"pid 1 (ziti controller run) started 2022-01-02T03:04:05Z holds the write lock"},
+		{&LockHolder{Pid: 2, Confirmed: true}, "pid 2 holds a read lock"},
+		{&LockHolder{Pid: 3, Command: "cat"}, "pid 3 (cat) has the file open"},
+	}
+
+	for _, test := range tests {
+		if got := test.holder.String(); got != test.want {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+	}
+}
+
+func TestLockError(t *testing.T) {
+	err := &LockError{Path: "ctrl.db", Err: errors.New("timeout")}
+	if got, want := err.Error(), "could not acquire the lock on ctrl.db: timeout"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	err.Holders = []*LockHolder{{Pid: 1, Confirmed: true, Exclusive: true}}
+	if got, want := err.Error(), "could not acquire the lock on ctrl.db, pid 1 holds the write lock: timeout"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
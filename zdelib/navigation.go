@@ -0,0 +1,224 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"errors"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"strings"
+)
+
+// MaxHistory is the number of locations kept in State.History.
+const MaxHistory = 100
+
+// SetPath moves the state to `path` and records the location in History. Locations after the current position in
+// History are discarded, as with a web browser.
+func (state *State) SetPath(path Path) {
+	path = append(Path{}, path...)
+
+	if path.Equal(state.Path) {
+		return
+	}
+
+	state.moveTo(path)
+
+	state.History = append(state.History[0:state.historyIndex+1], path)
+	if len(state.History) > MaxHistory {
+		state.History = state.History[len(state.History)-MaxHistory:]
+	}
+	state.historyIndex = len(state.History) - 1
+}
+
+// Previous moves the state to the location before the current position in History. If that bucket no longer exists
+// it is removed from History and an error is returned.
+func (state *State) Previous() error {
+	if state.historyIndex <= 0 {
+		return errors.New("no previous location")
+	}
+
+	target := state.historyIndex - 1
+	if err := state.checkHistoryEntry(target); err != nil {
+		return err
+	}
+
+	state.historyIndex = target
+	state.moveTo(state.History[state.historyIndex])
+
+	return nil
+}
+
+// Next moves the state to the location after the current position in History. If that bucket no longer exists it is
+// removed from History and an error is returned.
+func (state *State) Next() error {
+	if state.historyIndex >= len(state.History)-1 {
+		return errors.New("no next location")
+	}
+
+	target := state.historyIndex + 1
+	if err := state.checkHistoryEntry(target); err != nil {
+		return err
+	}
+
+	state.historyIndex = target
+	state.moveTo(state.History[state.historyIndex])
+
+	return nil
+}
+
+// Toggle moves the state to the location it was at before the last move, like `cd -` in a shell. If that bucket no
+// longer exists the location is forgotten and an error is returned.
+func (state *State) Toggle() error {
+	if state.previousPath == nil {
+		return errors.New("no previous location")
+	}
+
+	if !state.BucketExists(state.previousPath) {
+		missing := state.previousPath
+		state.previousPath = nil
+		return fmt.Errorf("previous location %s no longer exists", missing)
+	}
+
+	state.SetPath(state.previousPath)
+
+	return nil
+}
+
+// BucketExists returns true if the bucket at `path` exists.
+func (state *State) BucketExists(path Path) bool {
+	exists := false
+	_ = state.View(func(tx *bbolt.Tx) error {
+		exists = BucketAt(tx, path) != nil
+		return nil
+	})
+
+	return exists
+}
+
+// checkHistoryEntry returns an error and removes the entry at `index` from History if its bucket no longer exists.
+func (state *State) checkHistoryEntry(index int) error {
+	path := state.History[index]
+
+	if state.BucketExists(path) {
+		return nil
+	}
+
+	state.removeHistoryEntry(index)
+
+	// the locations on either side of the removed one may now be the same, keep only one of them
+	if index > 0 && index < len(state.History) && state.History[index-1].Equal(state.History[index]) {
+		state.removeHistoryEntry(index)
+	}
+
+	return fmt.Errorf("location %s no longer exists, removed it from history", path)
+}
+
+// HistoryIndex returns the position of the current location in History.
+func (state *State) HistoryIndex() int {
+	return state.historyIndex
+}
+
+// removeHistoryEntry removes the entry at `index` from History, keeping the current position on the same location.
+func (state *State) removeHistoryEntry(index int) {
+	state.History = append(state.History[0:index], state.History[index+1:]...)
+	if index <= state.historyIndex && state.historyIndex > 0 {
+		state.historyIndex--
+	}
+}
+
+// moveTo sets the path without recording it in History.
+func (state *State) moveTo(path Path) {
+	state.previousPath = state.Path
+	if state.previousPath == nil {
+		state.previousPath = Path{}
+	}
+	state.Path = path
+}
+
+// Resolve parses a path expression relative to the current path with ParseRelativePath. Every segment is checked in
+// a single read transaction and the error returned names the first segment that is not a bucket. If the path is not a
+// bucket but splitting its last segment on `.` gives one, the form paths were written in before PathSeparator, e.g.
+// `ziti.identities`, that bucket is returned. Quoted expressions are never split.
+func (state *State) Resolve(expr string) (Path, error) {
+	path, err := ParseRelativePath(state.Path, expr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = state.View(func(tx *bbolt.Tx) error {
+		bucket := BucketAt(tx, nil)
+
+		for i, segment := range path {
+			child := bucket.Bucket([]byte(segment))
+
+			if child == nil {
+				if dotted := dottedPath(tx, path); dotted != nil && !strings.ContainsAny(expr, `"\`) {
+					path = dotted
+					return nil
+				}
+
+				location := path[0:i]
+
+				if bucket.Get([]byte(segment)) != nil {
+					return fmt.Errorf("invalid path %s: %s in %s is not a bucket", expr, QuoteSegment(segment), location)
+				}
+				return fmt.Errorf("invalid path %s: %s not found in %s", expr, QuoteSegment(segment), location)
+			}
+
+			bucket = child
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return path, nil
+}
+
+// dottedPath returns `path` with its last segment split on `.` if that is a bucket. Otherwise, nil is returned.
+func dottedPath(tx *bbolt.Tx, path Path) Path {
+	if len(path) == 0 || !strings.Contains(path[len(path)-1], ".") {
+		return nil
+	}
+
+	dotted := append(Path{}, path[:len(path)-1]...)
+	for _, segment := range strings.Split(path[len(path)-1], ".") {
+		if segment != "" {
+			dotted = append(dotted, segment)
+		}
+	}
+
+	if BucketAt(tx, dotted) == nil {
+		return nil
+	}
+
+	return dotted
+}
+
+// EnterPath resolves a path expression with Resolve and moves the state to it.
+func (state *State) EnterPath(expr string) error {
+	path, err := state.Resolve(expr)
+
+	if err != nil {
+		return err
+	}
+
+	state.SetPath(path)
+
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"go.etcd.io/bbolt"
+	"testing"
+)
+
+func TestHistorySkipsRemovedBuckets(t *testing.T) {
+	state := newTestState(t, func(tx *bbolt.Tx) error {
+		return createBuckets(tx, Path{"ziti", "tmp"})
+	})
+
+	for _, expr := range []string{"/ziti", "tmp", ".."} {
+		if err := state.EnterPath(expr); err != nil {
+			t.Fatalf("cd %s: %v", expr, err)
+		}
+	}
+
+	err := state.DB.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte("ziti")).DeleteBucket([]byte("tmp"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := state.Previous(); err == nil {
+		t.Fatalf("expected an error moving to a removed bucket, moved to %s", state.Path)
+	}
+
+	if !state.Path.Equal(Path{"ziti"}) {
+		t.Errorf("expected to stay at /ziti, at %s", state.Path)
+	}
+
+	if count := state.CurrentBucketKeyCount(); count != 0 {
+		t.Errorf("expected 0 keys, got %d", count)
+	}
+
+	if len(state.History) != 2 {
+		t.Errorf("expected the removed location to be dropped from history, got %v", state.History)
+	}
+
+	if err := state.Previous(); err != nil || !state.Path.Equal(Path{}) {
+		t.Errorf("expected to move to /, at %s: %v", state.Path, err)
+	}
+}
+
+func TestToggleRemovedBucket(t *testing.T) {
+	state := newTestState(t, func(tx *bbolt.Tx) error {
+		return createBuckets(tx, Path{"ziti", "tmp"})
+	})
+
+	for _, expr := range []string{"/ziti/tmp", "/ziti"} {
+		if err := state.EnterPath(expr); err != nil {
+			t.Fatalf("cd %s: %v", expr, err)
+		}
+	}
+
+	err := state.DB.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte("ziti")).DeleteBucket([]byte("tmp"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := state.Toggle(); err == nil {
+		t.Fatalf("expected an error toggling to a removed bucket, moved to %s", state.Path)
+	}
+
+	if !state.Path.Equal(Path{"ziti"}) {
+		t.Errorf("expected to stay at /ziti, at %s", state.Path)
+	}
+}
+
+func TestCountKeysNilBucket(t *testing.T) {
+	if count := CountKeys(nil); count != 0 {
+		t.Errorf("expected 0 keys for a nil bucket, got %d", count)
+	}
+}
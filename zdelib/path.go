@@ -0,0 +1,183 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PathSeparator separates the segments of a path expression.
+const PathSeparator = "/"
+
+// Path is the location of a bucket or key as the names of the buckets leading to it from the root. An empty Path is
+// the root.
+//
+// Paths are written as segments separated by PathSeparator, e.g. `/ziti/identities`. A segment that contains a
+// separator, a quote, a backslash, white space or a `;`, or that is empty, `.` or `..`, is written in double quotes
+// with quotes and backslashes escaped by a backslash, e.g. `/ziti/"a/b"`.
+type Path []string
+
+// ParsePath parses a path expression starting at the root. See ParseRelativePath.
+func ParsePath(expr string) (Path, error) {
+	return ParseRelativePath(nil, expr)
+}
+
+// ParseRelativePath parses a path expression relative to `base`. A leading separator starts at the root instead,
+// an unquoted `..` moves up one level and an unquoted `.` or empty segment is ignored. A segment may be written in
+// double quotes and a backslash escapes the next character inside or outside of quotes.
+func ParseRelativePath(base Path, expr string) (Path, error) {
+	var path Path
+
+	if !strings.HasPrefix(expr, PathSeparator) {
+		path = append(path, base...)
+	}
+
+	segments, err := splitPath(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %s: %w", expr, err)
+	}
+
+	for _, segment := range segments {
+		if !segment.literal {
+			switch segment.name {
+			case "", ".":
+				continue
+			case "..":
+				if len(path) == 0 {
+					return nil, fmt.Errorf("invalid path %s: '..' goes above root", expr)
+				}
+				path = path[0 : len(path)-1]
+				continue
+			}
+		}
+
+		path = append(path, segment.name)
+	}
+
+	return path, nil
+}
+
+// pathSegment is a segment of a path expression. Literal segments were quoted or escaped and are never treated as
+// `.` or `..`.
+type pathSegment struct {
+	name    string
+	literal bool
+}
+
+// splitPath splits a path expression into segments, removing quotes and escapes.
+func splitPath(expr string) ([]pathSegment, error) {
+	var segments []pathSegment
+	current := pathSegment{}
+	builder := strings.Builder{}
+	inQuotes := false
+	escaped := false
+
+	for _, r := range expr {
+		switch {
+		case escaped:
+			builder.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+			current.literal = true
+		case r == '"':
+			inQuotes = !inQuotes
+			current.literal = true
+		case !inQuotes && string(r) == PathSeparator:
+			current.name = builder.String()
+			segments = append(segments, current)
+			current = pathSegment{}
+			builder.Reset()
+		default:
+			builder.WriteRune(r)
+		}
+	}
+
+	if escaped {
+		return nil, errors.New("trailing backslash")
+	}
+
+	if inQuotes {
+		return nil, errors.New("unterminated quote")
+	}
+
+	current.name = builder.String()
+	return append(segments, current), nil
+}
+
+// QuoteSegment returns `name` as it is written in a path expression, quoting it if required.
+func QuoteSegment(name string) string {
+	if !segmentNeedsQuotes(name) {
+		return name
+	}
+
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name)
+	return `"` + escaped + `"`
+}
+
+// segmentNeedsQuotes returns true if `name` would not parse back to itself when written as is.
+func segmentNeedsQuotes(name string) bool {
+	if name == "" || name == "." || name == ".." {
+		return true
+	}
+
+	return strings.ContainsAny(name, PathSeparator+"\"\\; \t\r\n")
+}
+
+// String returns the path as an absolute path expression that ParsePath parses back to the same Path.
+func (path Path) String() string {
+	builder := strings.Builder{}
+
+	for _, segment := range path {
+		builder.WriteString(PathSeparator)
+		builder.WriteString(QuoteSegment(segment))
+	}
+
+	if builder.Len() == 0 {
+		return PathSeparator
+	}
+
+	return builder.String()
+}
+
+// Child returns a new Path for `name` within this path.
+func (path Path) Child(name string) Path {
+	return append(append(Path{}, path...), name)
+}
+
+// Parent returns a new Path for the bucket containing this path. The parent of the root is the root.
+func (path Path) Parent() Path {
+	if len(path) == 0 {
+		return Path{}
+	}
+
+	return append(Path{}, path[0:len(path)-1]...)
+}
+
+// Equal returns true if both paths have the same segments.
+func (path Path) Equal(other Path) bool {
+	if len(path) != len(other) {
+		return false
+	}
+
+	for i := range path {
+		if path[i] != other[i] {
+			return false
+		}
+	}
+
+	return true
+}
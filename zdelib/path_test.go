@@ -0,0 +1,176 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"go.etcd.io/bbolt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRelativePath(t *testing.T) {
+	base := Path{"ziti", "identities"}
+
+	tests := []struct {
+		expr string
+		want Path
+	}{
+		{"", base},
+		{"/", nil},
+		{"/ziti/identities", Path{"ziti", "identities"}},
+		{"id0", Path{"ziti", "identities", "id0"}},
+		{"../services/", Path{"ziti", "services"}},
+		{"./id0//tags", Path{"ziti", "identities", "id0", "tags"}},
+		{"/ziti.identities", Path{"ziti.identities"}},
+		{`/ziti/"a/b"`, Path{"ziti", "a/b"}},
+		{`/ziti/a\/b`, Path{"ziti", "a/b"}},
+		{`/"a \"b\""/c`, Path{`a "b"`, "c"}},
+		{`/".."/"."/""`, Path{"..", ".", ""}},
+		{`/a\\b`, Path{`a\b`}},
+	}
+
+	for _, test := range tests {
+		got, err := ParseRelativePath(base, test.expr)
+		if err != nil {
+			t.Errorf("%s: %v", test.expr, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, test.want) && !(len(got) == 0 && len(test.want) == 0) {
+			t.Errorf("%s: got %#v, want %#v", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestParsePathErrors(t *testing.T) {
+	for _, expr := range []string{"..", "/ziti/../..", `/"ziti`, `/ziti\`} {
+		if _, err := ParsePath(expr); err == nil {
+			t.Errorf("expected an error parsing %s", expr)
+		}
+	}
+}
+
+func TestPathStringRoundTrip(t *testing.T) {
+	paths := []Path{
+		{},
+		{"ziti", "identities"},
+		{"a/b", "c"},
+		{`a "b"`, `c\d`},
+		{"..", ".", ""},
+		{"with space", "tab\there", "semi;colon", "new\nline"},
+		{"ziti.identities"},
+		{"ünï/cödé"},
+	}
+
+	for _, path := range paths {
+		expr := path.String()
+
+		parsed, err := ParsePath(expr)
+		if err != nil {
+			t.Errorf("%#v: %s does not parse: %v", path, expr, err)
+			continue
+		}
+
+		if !parsed.Equal(path) {
+			t.Errorf("%#v: %s parsed as %#v", path, expr, parsed)
+		}
+	}
+}
+
+func TestQuoteSegment(t *testing.T) {
+	tests := map[string]string{
+		"identities": "identities",
+		"a.b":        "a.b",
+		"a/b":        `"a/b"`,
+		`a"b`:        `"a\"b"`,
+		`a\b`:        `"a\\b"`,
+		"a b":        `"a b"`,
+		"..":         `".."`,
+		"":           `""`,
+	}
+
+	for name, want := range tests {
+		if got := QuoteSegment(name); got != want {
+			t.Errorf("QuoteSegment(%q): got %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestResolveDottedPath(t *testing.T) {
+	state := newTestState(t, func(tx *bbolt.Tx) error {
+		return createBuckets(tx, Path{"ziti", "identities"}, Path{"a.b"}, Path{"weird", "x.y"}, Path{"weird", "x", "y"})
+	})
+
+	tests := []struct {
+		expr string
+		want Path
+	}{
+		{"ziti.identities", Path{"ziti", "identities"}},
+		{"/ziti.identities", Path{"ziti", "identities"}},
+		{"/a.b", Path{"a.b"}},
+		{"/weird/x.y", Path{"weird", "x.y"}},
+	}
+
+	for _, test := range tests {
+		path, err := state.Resolve(test.expr)
+		if err != nil {
+			t.Errorf("%s: %v", test.expr, err)
+			continue
+		}
+
+		if !path.Equal(test.want) {
+			t.Errorf("%s: got %#v, want %#v", test.expr, path, test.want)
+		}
+	}
+
+	for _, expr := range []string{"ziti.missing", `"ziti.identities"`, `ziti\.identities`} {
+		if _, err := state.Resolve(expr); err == nil {
+			t.Errorf("%s: expected an error", expr)
+		}
+	}
+
+	state.SetPath(Path{"weird"})
+
+	if path, err := state.Resolve("x.y"); err != nil || !path.Equal(Path{"weird", "x.y"}) {
+		t.Errorf("expected the bucket named x.y to be preferred, got %#v: %v", path, err)
+	}
+}
+
+func TestDiffDottedPath(t *testing.T) {
+	state := newTestState(t, func(tx *bbolt.Tx) error {
+		if err := createBuckets(tx, Path{"ziti", "identities", "id0"}); err != nil {
+			return err
+		}
+		return tx.Bucket([]byte("ziti")).Put([]byte("other"), []byte("x"))
+	})
+
+	var differences []*Difference
+	err := Diff(state.DB, state.DB, Path{"ziti.identities"}, func(difference *Difference) error {
+		differences = append(differences, difference)
+		return nil
+	})
+	if err != nil {
+		t.Errorf("expected the dotted path to resolve to /ziti/identities: %v", err)
+	}
+
+	if len(differences) != 0 {
+		t.Errorf("expected no differences comparing a db with itself, got %d", len(differences))
+	}
+
+	err = Diff(state.DB, state.DB, Path{"ziti.missing"}, func(*Difference) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "path not found") {
+		t.Errorf("expected path not found, got: %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"errors"
+	"fmt"
+	"go.etcd.io/bbolt"
+)
+
+// View runs `fn` in a read transaction. If the state is pinned the pinned transaction is used, otherwise a new
+// transaction is opened for the call. All reads made by State go through View.
+func (state *State) View(fn func(tx *bbolt.Tx) error) error {
+	if state.pinned != nil {
+		return fn(state.pinned)
+	}
+
+	return state.DB.View(fn)
+}
+
+// Pin opens a read transaction that every read uses until Unpin is called, so a series of reads sees a single
+// consistent snapshot of the database. Writes made with the state release the transaction and pin a new one that
+// sees the write.
+func (state *State) Pin() error {
+	if state.pinned != nil {
+		return errors.New("already pinned")
+	}
+
+	tx, err := state.DB.Begin(false)
+
+	if err != nil {
+		return err
+	}
+
+	state.pinned = tx
+	return nil
+}
+
+// Unpin releases the pinned transaction, reads open their own transactions again.
+func (state *State) Unpin() error {
+	if state.pinned == nil {
+		return errors.New("not pinned")
+	}
+
+	err := state.pinned.Rollback()
+	state.pinned = nil
+
+	return err
+}
+
+// Pinned returns true if reads use a pinned transaction.
+func (state *State) Pinned() bool {
+	return state.pinned != nil
+}
+
+// PinnedTxId returns the ID of the transaction that committed the pinned snapshot, 0 if the state is not pinned.
+func (state *State) PinnedTxId() int {
+	if state.pinned == nil {
+		return 0
+	}
+
+	return state.pinned.ID()
+}
+
+// RefreshPin replaces the pinned transaction with a new one that sees the latest version of the database.
+func (state *State) RefreshPin() error {
+	if err := state.Unpin(); err != nil {
+		return err
+	}
+
+	return state.Pin()
+}
+
+// releasePin closes the pinned transaction, if there is one, before a write. bbolt cannot grow its memory map while
+// a read transaction is open, so writing with a pinned transaction may wait forever. The returned function pins a
+// new transaction that sees the write. If that fails the state is left unpinned and the error is returned, see
+// repinError.
+func (state *State) releasePin() func() error {
+	if state.pinned == nil {
+		return func() error { return nil }
+	}
+
+	if err := state.Unpin(); err != nil {
+		return func() error { return err }
+	}
+
+	return state.Pin
+}
+
+// repinError combines the error of a write with `pinErr`, the error from pinning again after it. A failed pin is
+// reported even if the write succeeded, as reads no longer use a single snapshot.
+func repinError(err, pinErr error) error {
+	if pinErr == nil {
+		return err
+	}
+
+	if err == nil {
+		return fmt.Errorf("reads are no longer pinned: %w", pinErr)
+	}
+
+	return fmt.Errorf("%w, and reads are no longer pinned: %v", err, pinErr)
+}
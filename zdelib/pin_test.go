@@ -0,0 +1,173 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"errors"
+	"go.etcd.io/bbolt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newPinTestState returns a writable State for a db with an empty `ziti` bucket. The db is mapped with room to grow
+// so that writes made with State.DB directly, standing in for a second handle, do not wait on the pinned transaction
+// to remap the file.
+func newPinTestState(t *testing.T) *State {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second, InitialMmapSize: 1 << 24})
+	if err != nil {
+		t.Fatalf("could not create db: %v", err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucket([]byte("ziti"))
+		return err
+	})
+	if err != nil {
+		t.Fatalf("could not populate db: %v", err)
+	}
+
+	state := &State{DB: db, History: []Path{{}}, cache: newLruCache(DefaultCacheSize), SkipBackup: true}
+	t.Cleanup(state.Done)
+
+	return state
+}
+
+// writeDirectly stores `key` in the `ziti` bucket without going through `state`.
+func writeDirectly(t *testing.T, state *State, key string) {
+	t.Helper()
+
+	err := state.DB.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte("ziti")).Put([]byte(key), []byte{})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// hasKey returns true if `state` sees `key` in the `ziti` bucket.
+func hasKey(state *State, key string) bool {
+	found := false
+
+	_ = state.View(func(tx *bbolt.Tx) error {
+		found = tx.Bucket([]byte("ziti")).Get([]byte(key)) != nil
+		return nil
+	})
+
+	return found
+}
+
+func TestPinIgnoresOtherWrites(t *testing.T) {
+	state := newPinTestState(t)
+
+	if err := state.Pin(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := state.Pin(); err == nil {
+		t.Error("expected an error pinning twice")
+	}
+
+	pinnedTxId := state.PinnedTxId()
+
+	writeDirectly(t, state, "a")
+
+	if hasKey(state, "a") {
+		t.Error("a pinned state should not see a write made through a second handle")
+	}
+
+	if err := state.RefreshPin(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !hasKey(state, "a") {
+		t.Error("expected the refreshed pin to see the write")
+	}
+
+	if state.PinnedTxId() <= pinnedTxId {
+		t.Errorf("expected the refreshed pin to be after tx %d, got %d", pinnedTxId, state.PinnedTxId())
+	}
+
+	if err := state.Unpin(); err != nil {
+		t.Fatal(err)
+	}
+
+	if state.Pinned() || state.PinnedTxId() != 0 {
+		t.Error("expected the state to be unpinned")
+	}
+
+	if err := state.Unpin(); err == nil {
+		t.Error("expected an error unpinning twice")
+	}
+}
+
+func TestWriteRepins(t *testing.T) {
+	state := newPinTestState(t)
+
+	if err := state.Pin(); err != nil {
+		t.Fatal(err)
+	}
+
+	pinnedTxId := state.PinnedTxId()
+
+	if err := state.Apply(&Mutation{Op: MutationSet, Path: Path{"ziti"}, Key: "a", Value: []byte{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !state.Pinned() {
+		t.Fatal("expected the state to be pinned again after a write")
+	}
+
+	if state.PinnedTxId() <= pinnedTxId {
+		t.Errorf("expected the new pin to be after tx %d, got %d", pinnedTxId, state.PinnedTxId())
+	}
+
+	if !hasKey(state, "a") {
+		t.Error("expected the new pin to see the write")
+	}
+
+	// staging validates the change in a write transaction that must not lose the pin either
+	if err := state.Begin(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := state.Stage(&Mutation{Op: MutationSet, Path: Path{"ziti"}, Key: "b", Value: []byte{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !state.Pinned() || hasKey(state, "b") {
+		t.Error("expected the state to stay pinned without seeing the staged change")
+	}
+}
+
+func TestRepinError(t *testing.T) {
+	writeErr := errors.New("write failed")
+	pinErr := errors.New("pin failed")
+
+	if err := repinError(writeErr, nil); err != writeErr {
+		t.Errorf("expected the write error, got %v", err)
+	}
+
+	if err := repinError(nil, pinErr); !errors.Is(err, pinErr) {
+		t.Errorf("expected a failed pin to be reported after a successful write, got %v", err)
+	}
+
+	if err := repinError(writeErr, pinErr); !errors.Is(err, writeErr) || err.Error() != "write failed, and reads are no longer pinned: pin failed" {
+		t.Errorf("expected both errors to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,569 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"fmt"
+	"github.com/openziti/storage/ast"
+	"github.com/openziti/storage/boltz"
+	"go.etcd.io/bbolt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultQueryLimit is the number of entities returned by QueryEntities when the filter has no limit clause.
+const DefaultQueryLimit = 100
+
+// QueryResult holds the entities matched by QueryEntities, one row of values per entity in the order of Fields.
+type QueryResult struct {
+	Fields []string
+	Rows   [][]interface{}
+
+	// Matched is the number of entities matching the filter before skip and limit were applied
+	Matched int64
+}
+
+// QueryEntities evaluates `filter`, written in the Ziti filter language used by the management API, against each
+// entity of `entityType` (see EntityTypePath) and returns the matches. Symbol types are inferred from the fields
+// stored in the entities: scalar fields are typed by their values, string lists such as roleAttributes and links are
+// sets and the keys of nested maps such as tags are named `tags.<key>`.
+//
+// The result holds the id of each entity followed by `fields`. If no fields are supplied, the name and every field
+// used by the filter are returned. Without a limit clause at most DefaultQueryLimit entities are returned.
+func (state *State) QueryEntities(entityType string, filter string, fields []string) (*QueryResult, error) {
+	var result *QueryResult
+
+	err := state.View(func(tx *bbolt.Tx) error {
+		bucket, err := entityTypeBucket(tx, entityType)
+
+		if err != nil {
+			return err
+		}
+
+		schema := inferSchema(bucket)
+
+		query, err := ast.Parse(schema, filter)
+
+		if err != nil {
+			return fmt.Errorf("invalid filter: %w", err)
+		}
+
+		columns, err := schema.columns(fields)
+
+		if err != nil {
+			return err
+		}
+
+		result, err = runQuery(bucket, schema, query, columns)
+		return err
+	})
+
+	return result, err
+}
+
+// entityField is a queryable field found in the entities of one type. `path` is the key of the field within the entity
+// bucket, preceded by the key of the nested bucket holding it for map fields.
+type entityField struct {
+	nodeType ast.NodeType
+	typed    bool
+	isSet    bool
+	isMap    bool
+	path     []string
+}
+
+// entitySchema implements ast.SymbolTypes for the fields found in the entities of one type.
+type entitySchema struct {
+	fields map[string]*entityField
+
+	// used lists the symbols looked up while parsing a filter, in order
+	used []string
+}
+
+// inferSchema reads every entity in `bucket` and records the name and type of each field. The first typed value seen
+// for a field decides its type, fields that only hold nil are typed as strings.
+func inferSchema(bucket *bbolt.Bucket) *entitySchema {
+	schema := &entitySchema{
+		fields: map[string]*entityField{
+			"id": {nodeType: ast.NodeTypeString, typed: true, path: []string{"id"}},
+		},
+	}
+
+	add := func(name string, fieldType boltz.FieldType, isSet bool, path ...string) {
+		nodeType, typed := nodeTypeOf(fieldType)
+
+		field, found := schema.fields[name]
+		if !found {
+			schema.fields[name] = &entityField{nodeType: nodeType, typed: typed, isSet: isSet, path: path}
+		} else if typed && !field.typed {
+			field.nodeType = nodeType
+			field.typed = true
+		}
+	}
+
+	cursor := bucket.Cursor()
+	for id, value := cursor.First(); id != nil; id, value = cursor.Next() {
+		if value != nil {
+			continue
+		}
+
+		entityBucket := bucket.Bucket(id)
+		entityCursor := entityBucket.Cursor()
+
+		for key, value := entityCursor.First(); key != nil; key, value = entityCursor.Next() {
+			name := string(key)
+
+			if value != nil {
+				fieldType, _ := boltz.GetTypeAndValue(value)
+				add(name, fieldType, false, name)
+				continue
+			}
+
+			nestedCursor := entityBucket.Bucket(key).Cursor()
+			for nestedKey, nestedValue := nestedCursor.First(); nestedKey != nil; nestedKey, nestedValue = nestedCursor.Next() {
+				entry := NewEntry(nestedKey, nestedValue)
+
+				if entry.IsListItem {
+					add(name, boltz.TypeString, true, name)
+					break
+				}
+
+				if !entry.IsBucket {
+					add(name+"."+entry.Name, entry.Type, false, name, entry.Name)
+					if _, found := schema.fields[name]; !found {
+						schema.fields[name] = &entityField{nodeType: ast.NodeTypeOther, isMap: true, path: []string{name}}
+					}
+				}
+			}
+		}
+	}
+
+	return schema
+}
+
+// nodeTypeOf returns the filter type of values with the boltz type `fieldType`. If it has none, such as for nil values,
+// string is returned with false.
+func nodeTypeOf(fieldType boltz.FieldType) (ast.NodeType, bool) {
+	switch fieldType {
+	case boltz.TypeString:
+		return ast.NodeTypeString, true
+	case boltz.TypeInt32, boltz.TypeInt64:
+		return ast.NodeTypeInt64, true
+	case boltz.TypeFloat64:
+		return ast.NodeTypeFloat64, true
+	case boltz.TypeBool:
+		return ast.NodeTypeBool, true
+	case boltz.TypeTime:
+		return ast.NodeTypeDatetime, true
+	}
+
+	return ast.NodeTypeString, false
+}
+
+// symbol returns the queryable field `name`, nil if there is none. Maps can be shown but not filtered on.
+func (schema *entitySchema) symbol(name string) *entityField {
+	if field := schema.fields[name]; field != nil && !field.isMap {
+		return field
+	}
+
+	return nil
+}
+
+func (schema *entitySchema) GetSymbolType(name string) (ast.NodeType, bool) {
+	field := schema.symbol(name)
+	if field == nil {
+		return 0, false
+	}
+
+	schema.use(name)
+	return field.nodeType, true
+}
+
+func (schema *entitySchema) GetSetSymbolTypes(string) ast.SymbolTypes {
+	// set items are plain strings, there are no entity types to query linked entities with
+	return nil
+}
+
+func (schema *entitySchema) IsSet(name string) (bool, bool) {
+	field := schema.symbol(name)
+	if field == nil {
+		return false, false
+	}
+
+	schema.use(name)
+	return field.isSet, true
+}
+
+// use records that the filter being parsed refers to `name`.
+func (schema *entitySchema) use(name string) {
+	for _, used := range schema.used {
+		if used == name {
+			return
+		}
+	}
+
+	schema.used = append(schema.used, name)
+}
+
+// columns returns the fields to show for each entity, id followed by `fields` if any are supplied and otherwise the
+// name and the fields used by the filter.
+func (schema *entitySchema) columns(fields []string) ([]string, error) {
+	if len(fields) == 0 {
+		if _, found := schema.fields["name"]; found {
+			fields = append(fields, "name")
+		}
+		fields = append(fields, schema.used...)
+	}
+
+	columns := []string{"id"}
+	seen := map[string]bool{"id": true}
+
+	for _, field := range fields {
+		if seen[field] {
+			continue
+		}
+		seen[field] = true
+
+		if _, found := schema.fields[field]; !found {
+			return nil, fmt.Errorf("unknown field %s, available fields: %s", field, strings.Join(schema.names(), ", "))
+		}
+
+		columns = append(columns, field)
+	}
+
+	return columns, nil
+}
+
+// names returns the sorted names of every field.
+func (schema *entitySchema) names() []string {
+	var result []string
+	for name := range schema.fields {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// queryRow is an entity matched by a query.
+type queryRow struct {
+	values     []interface{}
+	sortValues []interface{}
+}
+
+// runQuery evaluates `query` against each entity in `bucket` in id order and returns the values of `columns` for the
+// entities selected by its predicate, sort, skip and limit.
+func runQuery(bucket *bbolt.Bucket, schema *entitySchema, query ast.Query, columns []string) (*QueryResult, error) {
+	result := &QueryResult{Fields: columns}
+
+	sortFields := query.GetSortFields()
+	sorted := len(sortFields) > 0
+
+	skip := int64(0)
+	if query.GetSkip() != nil && *query.GetSkip() > 0 {
+		skip = *query.GetSkip()
+	}
+
+	limit := int64(DefaultQueryLimit)
+	if query.GetLimit() != nil {
+		limit = *query.GetLimit()
+	}
+
+	// without a sort the entities are matched in id order, so only the selected page has to be kept
+	inPage := func(index int64) bool {
+		return sorted || (index >= skip && (limit < 0 || index < skip+limit))
+	}
+
+	var rows []*queryRow
+
+	cursor := bucket.Cursor()
+	for id, value := cursor.First(); id != nil; id, value = cursor.Next() {
+		if value != nil {
+			continue
+		}
+
+		symbols := &entitySymbols{entitySchema: schema, id: id, bucket: bucket.Bucket(id), cursors: map[string]*listCursor{}}
+
+		if !query.EvalBool(symbols) {
+			continue
+		}
+
+		if inPage(result.Matched) {
+			row := &queryRow{}
+			for _, column := range columns {
+				row.values = append(row.values, symbols.value(column))
+			}
+			for _, sortField := range sortFields {
+				row.sortValues = append(row.sortValues, symbols.value(sortField.Symbol()))
+			}
+			rows = append(rows, row)
+		}
+
+		result.Matched++
+	}
+
+	if sorted {
+		sort.SliceStable(rows, func(i, j int) bool {
+			for index, sortField := range sortFields {
+				compare := compareQueryValues(rows[i].sortValues[index], rows[j].sortValues[index])
+				if compare != 0 {
+					return (compare < 0) == sortField.IsAscending()
+				}
+			}
+			return false
+		})
+
+		if skip >= int64(len(rows)) {
+			rows = nil
+		} else {
+			rows = rows[skip:]
+		}
+
+		if limit >= 0 && limit < int64(len(rows)) {
+			rows = rows[0:limit]
+		}
+	}
+
+	for _, row := range rows {
+		result.Rows = append(result.Rows, row.values)
+	}
+
+	return result, nil
+}
+
+// compareQueryValues orders the values of a sort field, nil first.
+func compareQueryValues(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	switch aValue := a.(type) {
+	case string:
+		if bValue, ok := b.(string); ok {
+			return strings.Compare(aValue, bValue)
+		}
+	case bool:
+		if bValue, ok := b.(bool); ok && aValue != bValue {
+			if aValue {
+				return 1
+			}
+			return -1
+		}
+		return 0
+	case time.Time:
+		if bValue, ok := b.(time.Time); ok {
+			switch {
+			case aValue.Before(bValue):
+				return -1
+			case aValue.After(bValue):
+				return 1
+			}
+			return 0
+		}
+	}
+
+	aNumber, aOk := queryNumber(a)
+	bNumber, bOk := queryNumber(b)
+	if aOk && bOk {
+		switch {
+		case aNumber < bNumber:
+			return -1
+		case aNumber > bNumber:
+			return 1
+		}
+		return 0
+	}
+
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// queryNumber returns a numeric query value as a float64.
+func queryNumber(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case int32:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	case float64:
+		return number, true
+	}
+	return 0, false
+}
+
+// entitySymbols implements ast.Symbols for one entity.
+type entitySymbols struct {
+	*entitySchema
+	id     []byte
+	bucket *bbolt.Bucket
+
+	// cursors holds the set cursor opened for each set symbol, whose current item is the value of the symbol
+	cursors map[string]*listCursor
+}
+
+// eval returns the boltz type and value of the symbol `name`.
+func (symbols *entitySymbols) eval(name string) (boltz.FieldType, []byte) {
+	if name == "id" {
+		return boltz.TypeString, symbols.id
+	}
+
+	if cursor := symbols.cursors[name]; cursor != nil && cursor.IsValid() {
+		return boltz.TypeString, cursor.Current()
+	}
+
+	field := symbols.fields[name]
+	if field == nil || field.isSet {
+		return boltz.TypeNil, nil
+	}
+
+	bucket := symbols.bucket
+	for _, key := range field.path[0 : len(field.path)-1] {
+		if bucket = bucket.Bucket([]byte(key)); bucket == nil {
+			return boltz.TypeNil, nil
+		}
+	}
+
+	return boltz.GetTypeAndValue(bucket.Get([]byte(field.path[len(field.path)-1])))
+}
+
+// value returns the decoded value of the field `name`: a native value for scalar fields, a []string for sets and a
+// map for map fields.
+func (symbols *entitySymbols) value(name string) interface{} {
+	field := symbols.fields[name]
+
+	if field != nil && (field.isSet || field.isMap) {
+		nested := symbols.bucket.Bucket([]byte(field.path[0]))
+		if nested == nil {
+			return nil
+		}
+
+		if field.isSet {
+			items := []string{}
+			for cursor := newListCursor(nested); cursor.IsValid(); cursor.Next() {
+				items = append(items, string(cursor.Current()))
+			}
+			return items
+		}
+
+		values := map[string]interface{}{}
+		_ = nested.ForEach(func(key, value []byte) error {
+			if value != nil {
+				values[string(key)] = FieldToValue(boltz.GetTypeAndValue(value))
+			}
+			return nil
+		})
+		return values
+	}
+
+	return FieldToValue(symbols.eval(name))
+}
+
+func (symbols *entitySymbols) EvalBool(name string) *bool {
+	return boltz.FieldToBool(symbols.eval(name))
+}
+
+func (symbols *entitySymbols) EvalString(name string) *string {
+	fieldType, value := symbols.eval(name)
+	if fieldType == boltz.TypeNil {
+		return nil
+	}
+	return boltz.FieldToString(fieldType, value)
+}
+
+func (symbols *entitySymbols) EvalInt64(name string) *int64 {
+	return boltz.FieldToInt64(symbols.eval(name))
+}
+
+func (symbols *entitySymbols) EvalFloat64(name string) *float64 {
+	return boltz.FieldToFloat64(symbols.eval(name))
+}
+
+func (symbols *entitySymbols) EvalDatetime(name string) *time.Time {
+	fieldType, value := symbols.eval(name)
+	return boltz.FieldToDatetime(fieldType, value, name)
+}
+
+func (symbols *entitySymbols) IsNil(name string) bool {
+	fieldType, _ := symbols.eval(name)
+	return fieldType == boltz.TypeNil
+}
+
+func (symbols *entitySymbols) OpenSetCursor(name string) ast.SetCursor {
+	field := symbols.fields[name]
+	if field == nil || !field.isSet {
+		return ast.NewEmptyCursor()
+	}
+
+	cursor := newListCursor(symbols.bucket.Bucket([]byte(field.path[0])))
+	symbols.cursors[name] = cursor
+	return cursor
+}
+
+func (symbols *entitySymbols) OpenSetCursorForQuery(string, ast.Query) ast.SetCursor {
+	// sub-queries are rejected when the filter is parsed as GetSetSymbolTypes returns nil
+	return ast.NewEmptyCursor()
+}
+
+// listCursor is an ast.SetCursor over the items of a string list bucket.
+type listCursor struct {
+	cursor  *bbolt.Cursor
+	current []byte
+	valid   bool
+}
+
+// newListCursor returns a listCursor positioned at the first item of `bucket`, which may be nil.
+func newListCursor(bucket *bbolt.Bucket) *listCursor {
+	result := &listCursor{}
+
+	if bucket != nil {
+		result.cursor = bucket.Cursor()
+		key, _ := result.cursor.First()
+		result.setCurrent(key)
+	}
+
+	return result
+}
+
+func (cursor *listCursor) setCurrent(key []byte) {
+	cursor.valid = key != nil
+	cursor.current = nil
+
+	if cursor.valid {
+		if keyType, value := boltz.GetTypeAndValue(key); keyType == boltz.TypeString {
+			cursor.current = append([]byte{}, value...)
+		} else {
+			cursor.current = append([]byte{}, key...)
+		}
+	}
+}
+
+func (cursor *listCursor) Next() {
+	if cursor.valid {
+		key, _ := cursor.cursor.Next()
+		cursor.setCurrent(key)
+	}
+}
+
+func (cursor *listCursor) IsValid() bool {
+	return cursor.valid
+}
+
+func (cursor *listCursor) Current() []byte {
+	return cursor.current
+}
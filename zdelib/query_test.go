@@ -0,0 +1,153 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// queryIds returns the ids of the identities matched by `filter`, and how many matched before skip and limit.
+func queryIds(t *testing.T, state *State, filter string) ([]string, int64) {
+	t.Helper()
+
+	result, err := state.QueryEntities("identities", filter, nil)
+	if err != nil {
+		t.Fatalf("%s: %v", filter, err)
+	}
+
+	var ids []string
+	for _, row := range result.Rows {
+		ids = append(ids, row[0].(string))
+	}
+
+	return ids, result.Matched
+}
+
+func TestQueryEntities(t *testing.T) {
+	state := newEntityTestState(t)
+
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{`true`, []string{"id0", "id1", "id2"}},
+		{`name = "bob"`, []string{"id1"}},
+		{`isAdmin = true`, []string{"id0"}},
+		{`score > 3`, []string{"id0", "id2"}},
+		{`score >= 2 and name != "carol"`, []string{"id0", "id1"}},
+		{`name contains "o"`, []string{"id1", "id2"}},
+		{`id in ["id0", "id2"]`, []string{"id0", "id2"}},
+		{`anyOf(roleAttributes) = "ops"`, []string{"id0", "id1"}},
+		{`anyOf(roleAttributes) = "admin"`, []string{"id0"}},
+		{`tags.env = "prod"`, []string{"id0"}},
+		{`tags.env = "dev" or score = 9`, []string{"id1", "id2"}},
+		{`true sort by score desc`, []string{"id2", "id0", "id1"}},
+		{`isAdmin = false sort by name desc`, []string{"id2", "id1"}},
+	}
+
+	for _, test := range tests {
+		if got, _ := queryIds(t, state, test.filter); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.filter, got, test.want)
+		}
+	}
+}
+
+func TestQueryEntitiesSkipAndLimit(t *testing.T) {
+	state := newEntityTestState(t)
+
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{`true limit 2`, []string{"id0", "id1"}},
+		{`true skip 1 limit 1`, []string{"id1"}},
+		{`true skip 5`, nil},
+		{`true sort by score skip 1 limit 1`, []string{"id0"}},
+		{`true sort by score desc skip 2`, []string{"id1"}},
+	}
+
+	for _, test := range tests {
+		got, matched := queryIds(t, state, test.filter)
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.filter, got, test.want)
+		}
+
+		// skip and limit do not change how many entities matched
+		if matched != 3 {
+			t.Errorf("%s: expected 3 matches, got %d", test.filter, matched)
+		}
+	}
+}
+
+func TestQueryEntitiesFields(t *testing.T) {
+	state := newEntityTestState(t)
+
+	result, err := state.QueryEntities("identities", `score > 3`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without fields the name and the fields used by the filter are returned
+	if want := []string{"id", "name", "score"}; !reflect.DeepEqual(result.Fields, want) {
+		t.Errorf("got fields %v, want %v", result.Fields, want)
+	}
+
+	if want := [][]interface{}{{"id0", "alice", int64(5)}, {"id2", "carol", int64(9)}}; !reflect.DeepEqual(result.Rows, want) {
+		t.Errorf("got rows %v, want %v", result.Rows, want)
+	}
+
+	result, err = state.QueryEntities("identities", `anyOf(roleAttributes) = "ops"`, []string{"tags.env", "id", "roleAttributes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"id", "tags.env", "roleAttributes"}; !reflect.DeepEqual(result.Fields, want) {
+		t.Errorf("got fields %v, want %v", result.Fields, want)
+	}
+
+	want := [][]interface{}{{"id0", "prod", []string{"admin", "ops"}}, {"id1", "dev", []string{"ops"}}}
+	if !reflect.DeepEqual(result.Rows, want) {
+		t.Errorf("got rows %v, want %v", result.Rows, want)
+	}
+}
+
+func TestQueryEntitiesErrors(t *testing.T) {
+	state := newEntityTestState(t)
+
+	tests := []struct {
+		entityType string
+		filter     string
+		fields     []string
+		want       string
+	}{
+		{"widgets", `true`, nil, "unknown entity type widgets"},
+		{"identities", `name =`, nil, "invalid filter"},
+		{"identities", `color = "red"`, nil, "invalid filter: unknown symbol 'color'"},
+		{"identities", `score = "five"`, nil, "invalid filter: operation score = \"five\" is not supported"},
+		// only the values of a map can be filtered on
+		{"identities", `tags = "prod"`, nil, "invalid filter: unknown symbol 'tags'"},
+		{"identities", `true`, []string{"color"}, "unknown field color"},
+	}
+
+	for _, test := range tests {
+		_, err := state.QueryEntities(test.entityType, test.filter, test.fields)
+
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s %s: expected an error containing %q, got %v", test.entityType, test.filter, test.want, err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"bytes"
+)
+
+// RangeOptions selects the keys of a bucket visited by an Iterator. Bounds apply in key order regardless of Reverse and
+// unset bounds are ignored.
+type RangeOptions struct {
+	// From is the lowest key visited
+	From []byte
+
+	// After only visits keys greater than it
+	After []byte
+
+	// To is the highest key visited
+	To []byte
+
+	// Before only visits keys less than it
+	Before []byte
+
+	// Prefix only visits keys starting with it
+	Prefix []byte
+
+	// Start is the key to resume a previous scan at, in the direction of the scan
+	Start []byte
+
+	// Reverse visits keys from highest to lowest
+	Reverse bool
+}
+
+// bound is one end of a key range.
+type bound struct {
+	key       []byte
+	inclusive bool
+}
+
+// lower returns the lowest bound of the range, nil if there is none.
+func (options *RangeOptions) lower() *bound {
+	var result *bound
+
+	tighten := func(key []byte, inclusive bool) {
+		if key == nil {
+			return
+		}
+
+		if result == nil {
+			result = &bound{key: key, inclusive: inclusive}
+			return
+		}
+
+		compare := bytes.Compare(key, result.key)
+		if compare > 0 || (compare == 0 && !inclusive) {
+			result = &bound{key: key, inclusive: inclusive}
+		}
+	}
+
+	tighten(options.From, true)
+	tighten(options.After, false)
+	tighten(options.Prefix, true)
+	if !options.Reverse {
+		tighten(options.Start, true)
+	}
+
+	return result
+}
+
+// upper returns the highest bound of the range, nil if there is none.
+func (options *RangeOptions) upper() *bound {
+	var result *bound
+
+	tighten := func(key []byte, inclusive bool) {
+		if key == nil {
+			return
+		}
+
+		if result == nil {
+			result = &bound{key: key, inclusive: inclusive}
+			return
+		}
+
+		compare := bytes.Compare(key, result.key)
+		if compare < 0 || (compare == 0 && !inclusive) {
+			result = &bound{key: key, inclusive: inclusive}
+		}
+	}
+
+	tighten(options.To, true)
+	tighten(options.Before, false)
+	tighten(prefixEnd(options.Prefix), false)
+	if options.Reverse {
+		tighten(options.Start, true)
+	}
+
+	return result
+}
+
+// prefixEnd returns the lowest key greater than every key starting with `prefix`, nil if there is none.
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[0 : i+1]
+		}
+	}
+
+	return nil
+}
+
+// below returns true if `key` is below the bound.
+func (b *bound) below(key []byte) bool {
+	compare := bytes.Compare(key, b.key)
+	return compare < 0 || (compare == 0 && !b.inclusive)
+}
+
+// above returns true if `key` is above the bound.
+func (b *bound) above(key []byte) bool {
+	compare := bytes.Compare(key, b.key)
+	return compare > 0 || (compare == 0 && !b.inclusive)
+}
+
+// ScanRange seeks to the start of the range selected by `options` in the bucket at `path` and calls `visit` with the
+// raw key and Entry of each key in the range, without reading the rest of the bucket. The scan stops when the range
+// is exhausted or `visit` returns false. The key passed to `visit` is only valid until it returns.
+func (state *State) ScanRange(path Path, options *RangeOptions, visit func(key []byte, entry Entry) bool) error {
+	iterator, err := state.Iterate(path, options)
+
+	if err != nil {
+		return err
+	}
+	defer func() { _ = iterator.Close() }()
+
+	for iterator.Next() {
+		if !visit(iterator.Key(), iterator.Entry()) {
+			break
+		}
+	}
+
+	return nil
+}
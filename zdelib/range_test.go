@@ -0,0 +1,174 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"go.etcd.io/bbolt"
+	"reflect"
+	"testing"
+)
+
+var testRangeKeys = []string{"a", "ab", "abc", "b", "ba", "c", "d"}
+
+// newRangeTestState returns a State with a `keys` bucket holding testRangeKeys, `ba` being a nested bucket.
+func newRangeTestState(t *testing.T) *State {
+	return newTestState(t, func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucket([]byte("keys"))
+		if err != nil {
+			return err
+		}
+
+		for _, key := range testRangeKeys {
+			if key == "ba" {
+				_, err = bucket.CreateBucket([]byte(key))
+			} else {
+				err = bucket.Put([]byte(key), []byte("x"))
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// scanKeys returns the keys visited by ScanRange, stopping after `limit` keys if it is greater than zero.
+func scanKeys(t *testing.T, state *State, options *RangeOptions, limit int) []string {
+	t.Helper()
+
+	var keys []string
+	err := state.ScanRange(Path{"keys"}, options, func(key []byte, _ Entry) bool {
+		keys = append(keys, string(key))
+		return limit <= 0 || len(keys) < limit
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return keys
+}
+
+func TestScanRange(t *testing.T) {
+	state := newRangeTestState(t)
+
+	tests := []struct {
+		name    string
+		options *RangeOptions
+		want    []string
+	}{
+		{"all", nil, testRangeKeys},
+		{"reverse", &RangeOptions{Reverse: true}, []string{"d", "c", "ba", "b", "abc", "ab", "a"}},
+		{"prefix", &RangeOptions{Prefix: []byte("ab")}, []string{"ab", "abc"}},
+		{"prefix reverse", &RangeOptions{Prefix: []byte("a"), Reverse: true}, []string{"abc", "ab", "a"}},
+		{"missing prefix", &RangeOptions{Prefix: []byte("bb")}, nil},
+		{"from to", &RangeOptions{From: []byte("ab"), To: []byte("b")}, []string{"ab", "abc", "b"}},
+		{"after before", &RangeOptions{After: []byte("ab"), Before: []byte("c")}, []string{"abc", "b", "ba"}},
+		{"after before reverse", &RangeOptions{After: []byte("ab"), Before: []byte("c"), Reverse: true}, []string{"ba", "b", "abc"}},
+		{"bounds between keys", &RangeOptions{From: []byte("aa"), To: []byte("bb")}, []string{"ab", "abc", "b", "ba"}},
+		{"bounds between keys reverse", &RangeOptions{From: []byte("aa"), To: []byte("bb"), Reverse: true}, []string{"ba", "b", "abc", "ab"}},
+		{"tightest bound wins", &RangeOptions{From: []byte("a"), After: []byte("b"), Before: []byte("d"), To: []byte("c")}, []string{"ba", "c"}},
+		{"empty range", &RangeOptions{After: []byte("c"), Before: []byte("d")}, nil},
+		{"past the end", &RangeOptions{From: []byte("e")}, nil},
+		{"start", &RangeOptions{Start: []byte("b")}, []string{"b", "ba", "c", "d"}},
+		{"start reverse", &RangeOptions{Start: []byte("b"), Reverse: true}, []string{"b", "abc", "ab", "a"}},
+		{"start within prefix", &RangeOptions{Prefix: []byte("a"), Start: []byte("ab")}, []string{"ab", "abc"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := scanKeys(t, state, test.options, 0); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestScanRangeResume(t *testing.T) {
+	state := newRangeTestState(t)
+
+	for _, reverse := range []bool{false, true} {
+		want := scanKeys(t, state, &RangeOptions{Reverse: reverse}, 0)
+
+		// read pages of two keys, reading one extra key to find where the next page starts as the list command does
+		var got []string
+		var start []byte
+		for page := 0; page < len(testRangeKeys); page++ {
+			keys := scanKeys(t, state, &RangeOptions{Reverse: reverse, Start: start}, 3)
+
+			if len(keys) < 3 {
+				got = append(got, keys...)
+				break
+			}
+
+			got = append(got, keys[0:2]...)
+			start = []byte(keys[2])
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("reverse %v: got %v, want %v", reverse, got, want)
+		}
+	}
+}
+
+func TestIteratorSeek(t *testing.T) {
+	state := newRangeTestState(t)
+
+	iterator, err := state.Iterate(Path{"keys"}, &RangeOptions{Before: []byte("d")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = iterator.Close() }()
+
+	iterator.Seek([]byte("bb"))
+
+	var keys []string
+	for iterator.Next() {
+		keys = append(keys, string(iterator.Key()))
+		if iterator.Entry().IsBucket != (iterator.Bucket() != nil) {
+			t.Errorf("%s: Bucket does not match Entry", iterator.Key())
+		}
+	}
+
+	if want := []string{"c"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("got %v, want %v", keys, want)
+	}
+}
+
+func TestIterateMissingBucket(t *testing.T) {
+	state := newRangeTestState(t)
+
+	if _, err := state.Iterate(Path{"missing"}, nil); err == nil {
+		t.Error("expected an error iterating a missing bucket")
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	tests := []struct {
+		prefix []byte
+		want   []byte
+	}{
+		{[]byte("ab"), []byte("ac")},
+		{[]byte{'a', 0xff}, []byte("b")},
+		{[]byte{0xff, 0xff}, nil},
+		{nil, nil},
+	}
+
+	for _, test := range tests {
+		if got := prefixEnd(test.prefix); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("prefixEnd(%q): got %q, want %q", test.prefix, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"time"
+)
+
+// SnapshotAttempts is the number of times CopySnapshot copies a database that is changing before giving up.
+const SnapshotAttempts = 5
+
+const (
+	// bbolt meta pages start after a 16 byte page header and hold the magic, version, page size, flags, root
+	// bucket, freelist, page count and txid before an fnv64a checksum of those fields
+	metaOffset         = 16
+	metaChecksumOffset = 56
+	metaSize           = 64
+	metaMagic          = 0xED0CDAED
+	metaVersion        = 2
+)
+
+// metaPage holds the fields of a bbolt meta page that identify the version of the database it describes.
+type metaPage struct {
+	valid    bool
+	pageSize uint32
+	txId     uint64
+	checksum uint64
+}
+
+// parseMeta parses the meta page at the start of `page`. bbolt writes meta pages in the byte order of the machine
+// that wrote them, which is little endian on every platform the controller is built for.
+func parseMeta(page []byte) metaPage {
+	if len(page) < metaOffset+metaSize {
+		return metaPage{}
+	}
+
+	data := page[metaOffset : metaOffset+metaSize]
+	meta := metaPage{
+		pageSize: binary.LittleEndian.Uint32(data[8:12]),
+		txId:     binary.LittleEndian.Uint64(data[48:56]),
+		checksum: binary.LittleEndian.Uint64(data[metaChecksumOffset:metaSize]),
+	}
+
+	hash := fnv.New64a()
+	_, _ = hash.Write(data[0:metaChecksumOffset])
+
+	meta.valid = binary.LittleEndian.Uint32(data[0:4]) == metaMagic &&
+		binary.LittleEndian.Uint32(data[4:8]) == metaVersion &&
+		meta.checksum == hash.Sum64()
+
+	return meta
+}
+
+// readMetas reads both meta pages of the bbolt database file at `path`.
+func readMetas(path string) ([2]metaPage, error) {
+	var metas [2]metaPage
+
+	file, err := os.Open(path)
+	if err != nil {
+		return metas, err
+	}
+	defer func() { _ = file.Close() }()
+
+	page := make([]byte, metaOffset+metaSize)
+
+	if _, err := io.ReadFull(file, page); err != nil {
+		return metas, fmt.Errorf("%s is not a bbolt database: %w", path, err)
+	}
+	metas[0] = parseMeta(page)
+
+	// the second meta page follows the first, bbolt uses the OS page size if the first is unreadable
+	pageSize := int64(os.Getpagesize())
+	if metas[0].valid {
+		pageSize = int64(metas[0].pageSize)
+	}
+
+	if _, err := file.ReadAt(page, pageSize); err != nil {
+		return metas, fmt.Errorf("%s is not a bbolt database: %w", path, err)
+	}
+	metas[1] = parseMeta(page)
+
+	if !metas[0].valid && !metas[1].valid {
+		return metas, fmt.Errorf("%s has no valid meta page", path)
+	}
+
+	return metas, nil
+}
+
+// CopySnapshot copies the bbolt database at `path` to a new temporary file without taking the file lock, so a
+// database in use by a running controller can be read. The meta pages of the live file are read before and after
+// the copy and the copy is retried if a transaction was committed while it was made, as the copy may then mix pages
+// from different versions. The meta page checksums of the copy are verified before its path is returned.
+func CopySnapshot(path string) (string, error) {
+	var lastErr error
+
+	for attempt := 1; attempt <= SnapshotAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+		}
+
+		snapshotPath, err := copySnapshot(path)
+
+		if err == nil {
+			return snapshotPath, nil
+		}
+
+		if errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+
+		lastErr = err
+	}
+
+	return "", fmt.Errorf("could not copy a consistent snapshot after %d attempts: %w", SnapshotAttempts, lastErr)
+}
+
+// copySnapshot makes a single attempt at CopySnapshot, removing the copy if it is not consistent.
+func copySnapshot(path string) (string, error) {
+	before, err := readMetas(path)
+	if err != nil {
+		return "", err
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := os.CreateTemp("", "ziti-db-explorer-snapshot-*.db")
+	if err != nil {
+		return "", err
+	}
+
+	snapshotPath := out.Name()
+
+	fail := func(err error) (string, error) {
+		_ = out.Close()
+		_ = os.Remove(snapshotPath)
+		return "", err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		return fail(err)
+	}
+
+	if err := out.Close(); err != nil {
+		return fail(err)
+	}
+
+	after, err := readMetas(path)
+	if err != nil {
+		return fail(err)
+	}
+
+	if before != after {
+		return fail(errors.New("the database changed while it was copied"))
+	}
+
+	copied, err := readMetas(snapshotPath)
+	if err != nil {
+		return fail(err)
+	}
+
+	if copied != before {
+		return fail(errors.New("the meta pages of the copy do not match the database"))
+	}
+
+	return snapshotPath, nil
+}
+
+// NewSnapshotState copies the database at `path` with CopySnapshot and opens the copy read only. The State shows the
+// database as it was when the copy was made and the copy is removed by Done.
+func NewSnapshotState(path string, options *OpenOptions) (*State, error) {
+	snapshotPath, err := CopySnapshot(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotOptions := *DefaultOpenOptions()
+	if options != nil {
+		snapshotOptions = *options
+	}
+	snapshotOptions.ReadOnly = true
+
+	state, err := NewStateWithOptions(snapshotPath, &snapshotOptions)
+
+	if err != nil {
+		_ = os.Remove(snapshotPath)
+		return nil, err
+	}
+
+	state.SnapshotPath = snapshotPath
+	state.SnapshotTime = time.Now()
+
+	return state, nil
+}
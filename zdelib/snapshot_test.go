@@ -0,0 +1,179 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestDbFile creates a closed bbolt database file holding a `ziti` bucket in a temporary directory.
+func newTestDbFile(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := bbolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Update(func(tx *bbolt.Tx) error { return createBuckets(tx, Path{"ziti"}) }); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// corruptMeta flips a byte of the txid of the meta page `index` of the database file at `path`.
+func corruptMeta(t *testing.T, path string, index int) {
+	t.Helper()
+
+	metas, err := readMetas(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pageSize := int64(metas[0].pageSize)
+	if !metas[0].valid {
+		pageSize = int64(metas[1].pageSize)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+
+	offset := int64(index)*pageSize + metaOffset + 48
+	b := make([]byte, 1)
+	if _, err := file.ReadAt(b, offset); err != nil {
+		t.Fatal(err)
+	}
+
+	b[0] ^= 0xff
+	if _, err := file.WriteAt(b, offset); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadMetas(t *testing.T) {
+	path := newTestDbFile(t)
+
+	metas, err := readMetas(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, meta := range metas {
+		if !meta.valid {
+			t.Errorf("meta page %d is not valid", i)
+		}
+
+		if meta.pageSize != uint32(os.Getpagesize()) {
+			t.Errorf("meta page %d: expected page size %d, got %d", i, os.Getpagesize(), meta.pageSize)
+		}
+	}
+
+	if metas[0].txId == metas[1].txId {
+		t.Errorf("expected the meta pages to describe different transactions, both are %d", metas[0].txId)
+	}
+
+	corruptMeta(t, path, 0)
+
+	metas, err = readMetas(path)
+	if err != nil {
+		t.Fatalf("expected one valid meta page to be enough: %v", err)
+	}
+
+	if metas[0].valid || !metas[1].valid {
+		t.Errorf("expected only the second meta page to be valid, got %v and %v", metas[0].valid, metas[1].valid)
+	}
+
+	corruptMeta(t, path, 1)
+
+	if _, err := readMetas(path); err == nil {
+		t.Error("expected an error when neither meta page is valid")
+	}
+}
+
+func TestReadMetasNotBolt(t *testing.T) {
+	dir := t.TempDir()
+
+	short := filepath.Join(dir, "short.db")
+	if err := os.WriteFile(short, []byte("not a db"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := readMetas(short); err == nil {
+		t.Error("expected an error for a file shorter than a meta page")
+	}
+
+	zeros := filepath.Join(dir, "zeros.db")
+	if err := os.WriteFile(zeros, make([]byte, 2*os.Getpagesize()), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := readMetas(zeros); err == nil {
+		t.Error("expected an error for a file without meta pages")
+	}
+
+	if meta := parseMeta(nil); meta.valid {
+		t.Error("expected an empty page to be invalid")
+	}
+}
+
+func TestNewSnapshotState(t *testing.T) {
+	path := newTestDbFile(t)
+
+	// hold the write lock as a running controller would
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	state, err := NewSnapshotState(path, &OpenOptions{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("could not open a snapshot of a locked db: %v", err)
+	}
+
+	snapshotPath := state.SnapshotPath
+	if snapshotPath == "" || snapshotPath == path {
+		t.Fatalf("expected a copy of the db, got %q", snapshotPath)
+	}
+
+	if _, err := state.Resolve("/ziti"); err != nil {
+		t.Errorf("expected the copy to hold the db contents: %v", err)
+	}
+
+	state.Done()
+
+	if _, err := os.Stat(snapshotPath); !os.IsNotExist(err) {
+		t.Errorf("expected the copy to be removed by Done, got %v", err)
+	}
+}
+
+func TestCopySnapshotMissing(t *testing.T) {
+	if _, err := CopySnapshot(filepath.Join(t.TempDir(), "missing.db")); !os.IsNotExist(err) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+}
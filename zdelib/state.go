@@ -14,39 +14,66 @@
 package zdelib
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"github.com/openziti/storage/boltz"
 	"go.etcd.io/bbolt"
-	"log"
-	"strings"
+	"os"
+	"time"
 )
 
 type State struct {
-	DB             *bbolt.DB
-	Path           []string
-	History        []string
-	pathEntryCache map[string][]Entry
-	pathCountCache map[string]int64
+	DB      *bbolt.DB
+	Path    Path
+	History []Path
+	cache   *lruCache
+
+	// BackupPath is the location of the backup made before the first write, empty if no backup has been made
+	BackupPath string
+
+	// SkipBackup disables the backup made before the first write
+	SkipBackup bool
+
+	inTransaction bool
+	pending       []*Mutation
+
+	historyIndex int
+	previousPath Path
+
+	pinned *bbolt.Tx
+
+	// SnapshotPath is the temporary copy opened by NewSnapshotState, empty if the database was opened directly
+	SnapshotPath string
+
+	// SnapshotTime is when the copy at SnapshotPath was made
+	SnapshotTime time.Time
 }
 
 // NewState creates a State which will attempt to open path as a bbolt database. If the path or db are invalid nil and
 // an error are returned. Otherwise, a newly initialized State is returned at the root of the database.
 func NewState(path string) (*State, error) {
-	db, err := Open(path)
+	return NewStateWithOptions(path, DefaultOpenOptions())
+}
+
+// NewStateWithOptions is the same as NewState but opens the database with the provided OpenOptions. If the lock on the
+// file cannot be acquired before the timeout a *LockError naming the processes holding it is returned.
+func NewStateWithOptions(path string, options *OpenOptions) (*State, error) {
+	db, err := OpenWithOptions(path, options)
 
 	if err != nil {
 		if err == bbolt.ErrTimeout {
-			log.Print("Could not acquire db lock. Ensure the ziti controller is stopped.");
+			holders, _ := FindLockHolders(path)
+			return nil, &LockError{Path: path, Holders: holders, Err: err}
 		}
 		return nil, err
 	}
 
 	return &State{
-		DB:             db,
-		Path:           nil,
-		History:        nil,
-		pathEntryCache: map[string][]Entry{},
-		pathCountCache: map[string]int64{},
+		DB:      db,
+		Path:    nil,
+		History: []Path{{}},
+		cache:   newLruCache(DefaultCacheSize),
 	}, nil
 }
 
@@ -58,8 +85,10 @@ func (state *State) DbStats() bbolt.Stats {
 // BucketStats returns the bbolt.BucketStats for the bucket the state's path currently points to
 func (state *State) BucketStats() bbolt.BucketStats {
 	var stats bbolt.BucketStats
-	_ = state.DB.View(func(tx *bbolt.Tx) error {
-		stats = state.CurrentBucket(tx).Stats()
+	_ = state.View(func(tx *bbolt.Tx) error {
+		if bucket := state.CurrentBucket(tx); bucket != nil {
+			stats = bucket.Stats()
+		}
 		return nil
 	})
 
@@ -68,12 +97,18 @@ func (state *State) BucketStats() bbolt.BucketStats {
 
 // CurrentBucket will return a *bbolt.Bucket matching the current state.path.
 func (state *State) CurrentBucket(tx *bbolt.Tx) *bbolt.Bucket {
-	if state.AtRoot() {
+	return BucketAt(tx, state.Path)
+}
+
+// BucketAt returns the *bbolt.Bucket at `path`. The root bucket is returned for an empty path and nil is returned if
+// the path does not exist.
+func BucketAt(tx *bbolt.Tx, path Path) *bbolt.Bucket {
+	if len(path) == 0 {
 		return tx.Cursor().Bucket()
 	}
 
-	targetBucket := tx.Bucket([]byte(state.Path[0]))
-	for _, nextBucket := range state.Path[1:] {
+	targetBucket := tx.Bucket([]byte(path[0]))
+	for _, nextBucket := range path[1:] {
 		if targetBucket == nil {
 			return nil
 		}
@@ -90,66 +125,148 @@ func (state *State) AtRoot() bool {
 
 // ListEntries returns an array of all Entries for the current bucket
 func (state *State) ListEntries() []Entry {
-	path := strings.Join(state.Path, ".")
-
-	if cachedEntries, ok := state.pathEntryCache[path]; ok {
-		return cachedEntries
-	}
+	return state.ListEntriesAt(state.Path)
+}
 
+// ListEntriesAt returns an array of all Entries for the bucket at `path`. If the path is not a bucket, nil is
+// returned. Listings are cached until the database changes, Refresh is called or they are evicted to stay within
+// the cache size.
+func (state *State) ListEntriesAt(path Path) []Entry {
+	cacheKey := "entries:" + path.String()
 	var entries []Entry
 
-	_ = state.DB.View(func(tx *bbolt.Tx) error {
-		cursor := state.CurrentBucket(tx).Cursor()
+	_ = state.View(func(tx *bbolt.Tx) error {
+		state.cache.sync(tx)
 
-		key, value := cursor.First()
-		for key != nil {
-			fieldType, fieldValue := boltz.GetTypeAndValue(value)
+		if cachedEntries, ok := state.cache.get(cacheKey); ok {
+			entries = cachedEntries.([]Entry)
+			return nil
+		}
 
-			// if type is nil, check to see if the key is typed (string list)
-			if fieldType == boltz.TypeNil {
-				keyType, keyValue := boltz.GetTypeAndValue(key)
+		bucket := BucketAt(tx, path)
 
-				if keyType == boltz.TypeString {
-					fieldType = boltz.TypeString
-					fieldValue = []byte("<nil>")
-					key = keyValue
-				}
-			}
+		if bucket == nil {
+			return nil
+		}
 
-			var valueString *string
+		cursor := bucket.Cursor()
 
-			if len(fieldValue) != 0 {
-				valueString = boltz.FieldToString(fieldType, fieldValue)
-			} else {
-				nilStr := "nil"
-				valueString = &nilStr
-			}
+		for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+			entries = append(entries, NewEntry(key, value))
+		}
 
-			entries = append(entries, Entry{Name: string(key), Type: fieldType, TypeString: TypeToString(fieldType), Value: fieldValue, ValueString: valueString})
+		state.cache.put(cacheKey, entries, entriesSize(entries))
+		return nil
+	})
 
-			key, fieldValue = cursor.Next()
+	return entries
+}
+
+// MaxCompletions is the number of keys in a bucket above which CompletionsAt only returns keys starting with the
+// prefix being completed, and the most names it returns.
+const MaxCompletions = 1000
+
+// CompletionsAt returns key names in the bucket at `path` to complete `prefix` with, only bucket names if
+// `bucketsOnly` is true. Every name is returned for buckets with up to MaxCompletions keys, so callers may filter them
+// as they like. For larger buckets the cursor seeks to `prefix` and up to MaxCompletions names starting with it are
+// returned, so completion does not read the whole bucket. Results are cached separately from full listings.
+func (state *State) CompletionsAt(path Path, prefix string, bucketsOnly bool) []string {
+	cacheKey := fmt.Sprintf("completions:%t:%s", bucketsOnly, path)
+	largeKey := "large:" + path.String()
+	var names []string
+
+	_ = state.View(func(tx *bbolt.Tx) error {
+		state.cache.sync(tx)
+
+		if cachedNames, ok := state.cache.get(cacheKey); ok {
+			names = cachedNames.([]string)
+			return nil
+		}
+
+		prefixKey := cacheKey + "\x00" + prefix
+		if cachedNames, ok := state.cache.get(prefixKey); ok {
+			names = cachedNames.([]string)
+			return nil
 		}
 
+		bucket := BucketAt(tx, path)
+
+		if bucket == nil {
+			return nil
+		}
+
+		if _, large := state.cache.get(largeKey); !large {
+			var complete bool
+			if names, complete = completions(bucket.Cursor(), nil, bucketsOnly); complete {
+				state.cache.put(cacheKey, names, namesSize(names))
+				return nil
+			}
+			state.cache.put(largeKey, true, 1)
+		}
+
+		names, _ = completions(bucket.Cursor(), []byte(prefix), bucketsOnly)
+		state.cache.put(prefixKey, names, namesSize(names))
 		return nil
 	})
 
-	state.pathEntryCache[path] = entries
-	return entries
+	return names
+}
+
+// completions reads up to MaxCompletions keys starting with `prefix` from `cursor` and returns their names, only
+// those of buckets if `bucketsOnly` is true. It also returns true if every key starting with `prefix` was read.
+func completions(cursor *bbolt.Cursor, prefix []byte, bucketsOnly bool) ([]string, bool) {
+	var names []string
+
+	read := 0
+	for key, value := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, value = cursor.Next() {
+		if read == MaxCompletions {
+			return names, false
+		}
+		read++
+
+		if !bucketsOnly || value == nil {
+			names = append(names, string(key))
+		}
+	}
+
+	return names, true
+}
+
+// namesSize approximates the memory used by `names`.
+func namesSize(names []string) int64 {
+	size := int64(0)
+
+	for _, name := range names {
+		size += int64(len(name)) + 16
+	}
+
+	return size
 }
 
 // Done is meant to be called when the state is no longer needed.
 func (state *State) Done() {
 	if state != nil && state.DB != nil {
+		if state.pinned != nil {
+			_ = state.Unpin()
+		}
 		_ = state.DB.Close()
+
+		if state.SnapshotPath != "" {
+			_ = os.Remove(state.SnapshotPath)
+		}
 	}
 }
 
 // Enter moves the state into the desired bucket name.
 func (state *State) Enter(name string) error {
-	return state.DB.View(func(tx *bbolt.Tx) error {
-		cursor := state.CurrentBucket(tx).Cursor()
+	return state.View(func(tx *bbolt.Tx) error {
+		bucket := state.CurrentBucket(tx)
+
+		if bucket == nil {
+			return errors.New("invalid bucket")
+		}
 
-		key, value := cursor.Seek([]byte(name))
+		key, value := bucket.Cursor().Seek([]byte(name))
 
 		if key == nil || string(key) != name {
 			return errors.New("invalid bucket name")
@@ -159,7 +276,7 @@ func (state *State) Enter(name string) error {
 			return errors.New("not a bucket")
 		}
 
-		state.Path = append(state.Path, name)
+		state.SetPath(state.Path.Child(name))
 
 		return nil
 	})
@@ -171,7 +288,7 @@ func (state *State) Back() error {
 		return errors.New("already at root")
 	}
 
-	state.Path = state.Path[0 : len(state.Path)-1]
+	state.SetPath(state.Path.Parent())
 
 	return nil
 }
@@ -180,7 +297,7 @@ func (state *State) Back() error {
 func (state *State) CurrentBucketKeyCount() int64 {
 	count := int64(0)
 
-	_ = state.DB.View(func(tx *bbolt.Tx) error {
+	_ = state.View(func(tx *bbolt.Tx) error {
 		count = state.CurrentBucketKeyCountInTx(tx)
 		return nil
 	})
@@ -190,20 +307,15 @@ func (state *State) CurrentBucketKeyCount() int64 {
 
 // CurrentBucketKeyCountInTx does the same thing as CurrentBucketKeyCount but withing an existing transaction
 func (state *State) CurrentBucketKeyCountInTx(tx *bbolt.Tx) int64 {
-	pathKey := strings.Join(state.Path, ".")
-	count, ok := state.pathCountCache[pathKey]
-
-	if ok {
-		return count
-	}
+	cacheKey := "count:" + state.Path.String()
+	state.cache.sync(tx)
 
-	cursor := state.CurrentBucket(tx).Cursor()
-
-	for k, _ := cursor.First(); k != nil; k, _ = cursor.Next() {
-		count++
+	if count, ok := state.cache.get(cacheKey); ok {
+		return count.(int64)
 	}
 
-	state.pathCountCache[pathKey] = count
+	count := CountKeys(state.CurrentBucket(tx))
+	state.cache.put(cacheKey, count, 8)
 
 	return count
 }
@@ -211,8 +323,11 @@ func (state *State) CurrentBucketKeyCountInTx(tx *bbolt.Tx) int64 {
 // GetValue returns the string value for a specific key in the current state's path location
 func (state *State) GetValue(key string) string {
 	var valueString *string
-	_ = state.DB.View(func(tx *bbolt.Tx) error {
-		value := state.CurrentBucket(tx).Get([]byte(key))
+	_ = state.View(func(tx *bbolt.Tx) error {
+		var value []byte
+		if bucket := state.CurrentBucket(tx); bucket != nil {
+			value = bucket.Get([]byte(key))
+		}
 
 		fieldType, valueType := boltz.GetTypeAndValue(value)
 
@@ -229,6 +344,74 @@ func (state *State) GetValue(key string) string {
 	return *valueString
 }
 
+// GetEntry returns the Entry for a specific key in the current state's path location. If the key does not exist, nil
+// is returned.
+func (state *State) GetEntry(key string) *Entry {
+	var entry *Entry
+	_ = state.View(func(tx *bbolt.Tx) error {
+		bucket := state.CurrentBucket(tx)
+
+		if bucket == nil {
+			return nil
+		}
+
+		if bucket.Bucket([]byte(key)) != nil {
+			entry = &Entry{Name: key, Type: boltz.TypeNil, TypeString: TypeToString(boltz.TypeNil), IsBucket: true}
+			return nil
+		}
+
+		value := bucket.Get([]byte(key))
+		if value == nil {
+			return nil
+		}
+
+		fieldType, fieldValue := boltz.GetTypeAndValue(value)
+		entry = &Entry{
+			Name:        key,
+			Type:        fieldType,
+			TypeString:  TypeToString(fieldType),
+			Value:       append([]byte(nil), fieldValue...),
+			ValueString: boltz.FieldToString(fieldType, fieldValue),
+		}
+
+		return nil
+	})
+
+	return entry
+}
+
+// NewEntry returns an Entry for a key and value as returned by a bbolt.Cursor. A nil value denotes a bucket.
+func NewEntry(key, value []byte) Entry {
+	isBucket := value == nil
+	fieldType, fieldValue := boltz.GetTypeAndValue(value)
+
+	isListItem := false
+
+	// if type is nil, check to see if the key is typed (string list)
+	if fieldType == boltz.TypeNil {
+		keyType, keyValue := boltz.GetTypeAndValue(key)
+
+		if keyType == boltz.TypeString {
+			fieldType = boltz.TypeString
+			fieldValue = []byte("<nil>")
+			key = keyValue
+			isListItem = true
+		}
+	}
+
+	var valueString *string
+
+	if len(fieldValue) != 0 {
+		valueString = boltz.FieldToString(fieldType, fieldValue)
+	} else {
+		nilStr := "nil"
+		valueString = &nilStr
+	}
+
+	// values are copied as the memory bbolt returns is only valid for the life of the transaction
+	return Entry{Name: string(key), Type: fieldType, TypeString: TypeToString(fieldType), Value: append([]byte(nil), fieldValue...), ValueString: valueString, IsBucket: isBucket, IsListItem: isListItem}
+}
+
 // Entry is a struct that represents a value field from the bbolt database with the key being set to the Name property.
 // Type information and string representations of the value are also provided.
 type Entry struct {
@@ -237,4 +420,8 @@ type Entry struct {
 	TypeString  string
 	Value       []byte
 	ValueString *string
+	IsBucket    bool
+
+	// IsListItem is true for the typed keys of a string list bucket, Name holds the list item
+	IsListItem bool
 }
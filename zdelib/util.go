@@ -35,7 +35,7 @@ func TypeToString(fieldType boltz.FieldType) string {
 	case boltz.TypeFloat64:
 		return "float64"
 	case boltz.TypeInt64:
-		return "int65"
+		return "int64"
 	case boltz.TypeNil:
 		return "nil"
 	}
@@ -43,8 +43,66 @@ func TypeToString(fieldType boltz.FieldType) string {
 	return "unknown"
 }
 
-// Open attempts to open the provided path as a bbolt.DB. Returns a bbolt.Db or an error
+// FieldToValue decodes a boltz typed value into its native Go type (string, bool, int32, int64, float64 or
+// time.Time). nil is returned for boltz.TypeNil and for values that cannot be decoded.
+func FieldToValue(fieldType boltz.FieldType, value []byte) interface{} {
+	switch fieldType {
+	case boltz.TypeString:
+		return string(value)
+	case boltz.TypeBool:
+		if result := boltz.BytesToBool(value); result != nil {
+			return *result
+		}
+	case boltz.TypeInt32:
+		if result := boltz.BytesToInt32(value); result != nil {
+			return *result
+		}
+	case boltz.TypeInt64:
+		if result := boltz.BytesToInt64(value); result != nil {
+			return *result
+		}
+	case boltz.TypeFloat64:
+		if result := boltz.BytesToFloat64(value); result != nil {
+			return *result
+		}
+	case boltz.TypeTime:
+		result := time.Time{}
+		if err := result.UnmarshalBinary(value); err == nil {
+			return result
+		}
+	}
+
+	return nil
+}
+
+// OpenOptions control how a bbolt database file is opened.
+type OpenOptions struct {
+	// Timeout is how long to wait for the file lock before returning bbolt.ErrTimeout
+	Timeout time.Duration
+
+	// ReadOnly opens the file with a shared lock and disallows writes
+	ReadOnly bool
+}
+
+// DefaultOpenOptions returns the OpenOptions used by Open.
+func DefaultOpenOptions() *OpenOptions {
+	return &OpenOptions{
+		Timeout:  2 * time.Second,
+		ReadOnly: true,
+	}
+}
+
+// Open attempts to open the provided path as a read only bbolt.DB. Returns a bbolt.Db or an error
 func Open(path string) (*bbolt.DB, error) {
+	return OpenWithOptions(path, DefaultOpenOptions())
+}
+
+// OpenWithOptions is the same as Open but allows the lock timeout and read only mode to be specified.
+func OpenWithOptions(path string, options *OpenOptions) (*bbolt.DB, error) {
+	if options == nil {
+		options = DefaultOpenOptions()
+	}
+
 	fileInfo, err := os.Stat(path)
 
 	if err != nil {
@@ -56,7 +114,7 @@ func Open(path string) (*bbolt.DB, error) {
 	}
 
 	return bbolt.Open(path, 0666, &bbolt.Options{
-		Timeout:  2 * time.Second,
-		ReadOnly: true,
+		Timeout:  options.Timeout,
+		ReadOnly: options.ReadOnly,
 	})
 }
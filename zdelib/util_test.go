@@ -0,0 +1,68 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"go.etcd.io/bbolt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestState creates a database in a temporary directory, populates it with `setup` and returns a writable State
+// for it that is closed when the test ends.
+func newTestState(t *testing.T, setup func(tx *bbolt.Tx) error) *State {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := bbolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatalf("could not create db: %v", err)
+	}
+
+	if err := db.Update(setup); err != nil {
+		t.Fatalf("could not populate db: %v", err)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("could not close db: %v", err)
+	}
+
+	state, err := NewStateWithOptions(path, &OpenOptions{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("could not open db: %v", err)
+	}
+	t.Cleanup(state.Done)
+
+	return state
+}
+
+// createBuckets creates each path in `paths` within `tx`, including any missing parents.
+func createBuckets(tx *bbolt.Tx, paths ...Path) error {
+	for _, path := range paths {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(path[0]))
+		if err != nil {
+			return err
+		}
+
+		for _, name := range path[1:] {
+			if bucket, err = bucket.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
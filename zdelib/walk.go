@@ -0,0 +1,160 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"errors"
+	"fmt"
+	"go.etcd.io/bbolt"
+)
+
+// SkipBucket may be returned by a WalkFunc. For a bucket its keys are not visited, for any other key the rest of the
+// bucket holding it is skipped.
+var SkipBucket = errors.New("skip this bucket")
+
+// SkipAll may be returned by a WalkFunc to stop the walk without an error.
+var SkipAll = errors.New("skip everything")
+
+// WalkOptions controls which keys Walk visits.
+type WalkOptions struct {
+	// MaxDepth is the number of bucket levels below the starting bucket to visit, 0 for no limit
+	MaxDepth int
+
+	// BucketsOnly skips keys that are not buckets
+	BucketsOnly bool
+}
+
+// WalkEntry is a single key visited by Walk.
+type WalkEntry struct {
+	// Path is the location of the bucket holding the key
+	Path Path
+
+	// Depth is 1 for keys in the starting bucket, 2 for keys in its buckets and so on
+	Depth int
+
+	// Key is the raw key, Entry.Name may differ for the typed keys of string lists
+	Key []byte
+
+	Entry Entry
+
+	// Bucket is the bucket the key names, nil if it is not a bucket. It is only valid until the WalkFunc returns.
+	Bucket *bbolt.Bucket
+
+	// Last is true for the last key visited in its bucket
+	Last bool
+}
+
+// WalkFunc is called by Walk for each key. Returning SkipBucket or SkipAll prunes the walk, any other error stops it
+// and is returned by Walk.
+type WalkFunc func(entry *WalkEntry) error
+
+// Walk visits the bucket at `path` depth first in a single read transaction, calling `visit` for every key in cursor
+// order before visiting the keys of the bucket it names.
+func (state *State) Walk(path Path, options *WalkOptions, visit WalkFunc) error {
+	if options == nil {
+		options = &WalkOptions{}
+	}
+
+	err := state.View(func(tx *bbolt.Tx) error {
+		bucket := BucketAt(tx, path)
+
+		if bucket == nil {
+			return fmt.Errorf("bucket not found: %s", path)
+		}
+
+		return walkBucket(bucket, path, 1, options, visit)
+	})
+
+	if err == SkipAll || err == SkipBucket {
+		return nil
+	}
+
+	return err
+}
+
+// walkBucket visits the keys of `bucket`, which is located at `path`, and recurses into nested buckets.
+func walkBucket(bucket *bbolt.Bucket, path Path, depth int, options *WalkOptions, visit WalkFunc) error {
+	var previous *WalkEntry
+
+	// entries are visited one behind the cursor so Last can be set on the final entry of the bucket
+	flush := func(entry *WalkEntry) error {
+		if entry == nil {
+			return nil
+		}
+
+		err := visit(entry)
+
+		if err == SkipBucket && entry.Bucket != nil {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if entry.Bucket != nil && (options.MaxDepth == 0 || depth < options.MaxDepth) {
+			return walkBucket(entry.Bucket, path.Child(string(entry.Key)), depth+1, options, visit)
+		}
+
+		return nil
+	}
+
+	cursor := bucket.Cursor()
+	for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+		if value != nil && options.BucketsOnly {
+			continue
+		}
+
+		entry := &WalkEntry{Path: path, Depth: depth, Key: key, Entry: NewEntry(key, value)}
+
+		if value == nil {
+			entry.Bucket = bucket.Bucket(key)
+		}
+
+		if err := flush(previous); err != nil {
+			return walkError(err)
+		}
+		previous = entry
+	}
+
+	if previous != nil {
+		previous.Last = true
+	}
+
+	return walkError(flush(previous))
+}
+
+// walkError stops SkipBucket returned for a key that is not a bucket from skipping more than the bucket holding it.
+func walkError(err error) error {
+	if err == SkipBucket {
+		return nil
+	}
+	return err
+}
+
+// CountKeys returns the number of keys directly within `bucket`. Zero is returned for a nil bucket.
+func CountKeys(bucket *bbolt.Bucket) int64 {
+	count := int64(0)
+
+	if bucket == nil {
+		return count
+	}
+
+	cursor := bucket.Cursor()
+	for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
+		count++
+	}
+
+	return count
+}
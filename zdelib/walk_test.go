@@ -0,0 +1,162 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"errors"
+	"fmt"
+	"go.etcd.io/bbolt"
+	"reflect"
+	"testing"
+)
+
+// newWalkTestState returns a State for a db holding:
+//
+//	/a/b/x = 1
+//	/a/c/
+//	/a/d = 2
+//	/g/h = 3
+//	/g/i = 4
+func newWalkTestState(t *testing.T) *State {
+	return newTestState(t, func(tx *bbolt.Tx) error {
+		if err := createBuckets(tx, Path{"a", "b"}, Path{"a", "c"}, Path{"g"}); err != nil {
+			return err
+		}
+
+		a := tx.Bucket([]byte("a"))
+		g := tx.Bucket([]byte("g"))
+
+		for _, put := range []error{
+			a.Bucket([]byte("b")).Put([]byte("x"), []byte("1")),
+			a.Put([]byte("d"), []byte("2")),
+			g.Put([]byte("h"), []byte("3")),
+			g.Put([]byte("i"), []byte("4")),
+		} {
+			if put != nil {
+				return put
+			}
+		}
+
+		return nil
+	})
+}
+
+// walkVisits walks `path` and returns each visited key as `<path> <depth>`, followed by ` last` if Last was set.
+// `visit`, if not nil, is called for each key and its result returned to Walk.
+func walkVisits(t *testing.T, state *State, path Path, options *WalkOptions, visit WalkFunc) []string {
+	t.Helper()
+
+	var visits []string
+
+	err := state.Walk(path, options, func(entry *WalkEntry) error {
+		if (entry.Bucket != nil) != entry.Entry.IsBucket {
+			t.Errorf("%s: Bucket does not match Entry", entry.Path.Child(entry.Entry.Name))
+		}
+
+		line := fmt.Sprintf("%s %d", entry.Path.Child(entry.Entry.Name), entry.Depth)
+		if entry.Last {
+			line += " last"
+		}
+		visits = append(visits, line)
+
+		if visit != nil {
+			return visit(entry)
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("walk failed: %v", err)
+	}
+
+	return visits
+}
+
+// skipAt returns a WalkFunc returning `err` for the key at `target`.
+func skipAt(target string, err error) WalkFunc {
+	return func(entry *WalkEntry) error {
+		if entry.Path.Child(entry.Entry.Name).String() == target {
+			return err
+		}
+		return nil
+	}
+}
+
+func TestWalk(t *testing.T) {
+	state := newWalkTestState(t)
+
+	tests := []struct {
+		name    string
+		path    Path
+		options *WalkOptions
+		visit   WalkFunc
+		want    []string
+	}{
+		{"everything", nil, nil, nil, []string{
+			"/a 1", "/a/b 2", "/a/b/x 3 last", "/a/c 2", "/a/d 2 last", "/g 1 last", "/g/h 2", "/g/i 2 last",
+		}},
+		{"from a bucket", Path{"a"}, nil, nil, []string{
+			"/a/b 1", "/a/b/x 2 last", "/a/c 1", "/a/d 1 last",
+		}},
+		{"max depth 1", nil, &WalkOptions{MaxDepth: 1}, nil, []string{
+			"/a 1", "/g 1 last",
+		}},
+		{"max depth 2", nil, &WalkOptions{MaxDepth: 2}, nil, []string{
+			"/a 1", "/a/b 2", "/a/c 2", "/a/d 2 last", "/g 1 last", "/g/h 2", "/g/i 2 last",
+		}},
+		// c is last once the value d after it is skipped
+		{"buckets only", nil, &WalkOptions{BucketsOnly: true}, nil, []string{
+			"/a 1", "/a/b 2", "/a/c 2 last", "/g 1 last",
+		}},
+		{"skip a bucket", nil, nil, skipAt("/a", SkipBucket), []string{
+			"/a 1", "/g 1 last", "/g/h 2", "/g/i 2 last",
+		}},
+		{"skip a nested bucket", nil, nil, skipAt("/a/b", SkipBucket), []string{
+			"/a 1", "/a/b 2", "/a/c 2", "/a/d 2 last", "/g 1 last", "/g/h 2", "/g/i 2 last",
+		}},
+		// skipping at a value skips the rest of the bucket holding it
+		{"skip at a value", nil, nil, skipAt("/g/h", SkipBucket), []string{
+			"/a 1", "/a/b 2", "/a/b/x 3 last", "/a/c 2", "/a/d 2 last", "/g 1 last", "/g/h 2",
+		}},
+		{"skip all", nil, nil, skipAt("/a/b/x", SkipAll), []string{
+			"/a 1", "/a/b 2", "/a/b/x 3 last",
+		}},
+	}
+
+	for _, test := range tests {
+		if got := walkVisits(t, state, test.path, test.options, test.visit); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestWalkErrors(t *testing.T) {
+	state := newWalkTestState(t)
+
+	failed := errors.New("failed")
+	visits := 0
+
+	err := state.Walk(nil, nil, func(entry *WalkEntry) error {
+		visits++
+		return failed
+	})
+
+	if err != failed || visits != 1 {
+		t.Errorf("expected the walk to stop with the error of the first visit, got %v after %d visits", err, visits)
+	}
+
+	if err := state.Walk(Path{"missing"}, nil, func(*WalkEntry) error { return nil }); err == nil {
+		t.Error("expected an error walking a missing bucket")
+	}
+}
@@ -0,0 +1,273 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"errors"
+	"fmt"
+	"github.com/openziti/storage/boltz"
+	"go.etcd.io/bbolt"
+	"time"
+)
+
+// MutationOp identifies the kind of change a Mutation makes.
+type MutationOp string
+
+const (
+	MutationSet          MutationOp = "set"
+	MutationDelete       MutationOp = "rm"
+	MutationCreateBucket MutationOp = "mkbucket"
+	MutationDeleteBucket MutationOp = "rmbucket"
+)
+
+// Mutation is a single change to the key `Key` in the bucket at `Path`. Value holds the boltz typed value for
+// MutationSet. Recursive allows MutationDeleteBucket to remove buckets that are not empty.
+type Mutation struct {
+	Op        MutationOp
+	Path      Path
+	Key       string
+	Value     []byte
+	Recursive bool
+}
+
+// String returns a human-readable description of the mutation.
+func (mutation *Mutation) String() string {
+	fullPath := mutation.Path.Child(mutation.Key)
+
+	switch mutation.Op {
+	case MutationSet:
+		fieldType, fieldValue := boltz.GetTypeAndValue(mutation.Value)
+		value := "nil"
+		if valueString := boltz.FieldToString(fieldType, fieldValue); valueString != nil {
+			value = *valueString
+		}
+		return fmt.Sprintf("set %s = (%s) %s", fullPath, TypeToString(fieldType), value)
+	case MutationDeleteBucket:
+		if mutation.Recursive {
+			return fmt.Sprintf("rmbucket -r %s", fullPath)
+		}
+	}
+
+	return fmt.Sprintf("%s %s", mutation.Op, fullPath)
+}
+
+// Apply applies the mutations in a single write transaction. Either all mutations are applied or none are. Unless
+// SkipBackup is set, a copy of the database file is written to BackupPath before the first write.
+func (state *State) Apply(mutations ...*Mutation) error {
+	if state.DB.IsReadOnly() {
+		return errors.New("db is open read only")
+	}
+
+	if err := state.backup(); err != nil {
+		return fmt.Errorf("could not back up db, no changes were made: %w", err)
+	}
+
+	defer state.Refresh()
+	repin := state.releasePin()
+
+	err := state.DB.Update(func(tx *bbolt.Tx) error {
+		for _, mutation := range mutations {
+			if err := applyMutation(tx, mutation); err != nil {
+				return fmt.Errorf("%s: %w", mutation, err)
+			}
+		}
+		return nil
+	})
+
+	return repinError(err, repin())
+}
+
+// errValidated is used to roll back the transaction used by validate.
+var errValidated = errors.New("validated")
+
+// Begin starts a transaction. Until Commit or Rollback is called, Stage adds mutations to a pending list instead of
+// applying them.
+func (state *State) Begin() error {
+	if state.DB.IsReadOnly() {
+		return errors.New("db is open read only")
+	}
+
+	if state.inTransaction {
+		return errors.New("a transaction is already in progress")
+	}
+
+	state.inTransaction = true
+	state.pending = nil
+
+	return nil
+}
+
+// InTransaction returns true if Begin has been called and the transaction has not been committed or rolled back.
+func (state *State) InTransaction() bool {
+	return state.inTransaction
+}
+
+// Pending returns the mutations staged in the current transaction.
+func (state *State) Pending() []*Mutation {
+	return state.pending
+}
+
+// Stage adds the mutations to the current transaction after checking that they, along with the mutations already
+// staged, can be applied to the db as it is now. If no transaction is in progress the mutations are applied
+// immediately.
+func (state *State) Stage(mutations ...*Mutation) error {
+	if !state.inTransaction {
+		return state.Apply(mutations...)
+	}
+
+	if err := state.validate(append(append([]*Mutation{}, state.pending...), mutations...)); err != nil {
+		return err
+	}
+
+	state.pending = append(state.pending, mutations...)
+	return nil
+}
+
+// Commit applies all staged mutations in a single write transaction and ends the transaction. If they cannot be
+// applied no changes are made and the transaction remains in progress.
+func (state *State) Commit() error {
+	if !state.inTransaction {
+		return errors.New("no transaction in progress")
+	}
+
+	if err := state.Apply(state.pending...); err != nil {
+		return err
+	}
+
+	state.inTransaction = false
+	state.pending = nil
+
+	return nil
+}
+
+// Rollback discards all staged mutations and ends the transaction.
+func (state *State) Rollback() error {
+	if !state.inTransaction {
+		return errors.New("no transaction in progress")
+	}
+
+	state.inTransaction = false
+	state.pending = nil
+
+	return nil
+}
+
+// validate applies the mutations in a write transaction that is always rolled back, returning the first error
+// encountered.
+func (state *State) validate(mutations []*Mutation) error {
+	repin := state.releasePin()
+
+	err := state.DB.Update(func(tx *bbolt.Tx) error {
+		for _, mutation := range mutations {
+			if err := applyMutation(tx, mutation); err != nil {
+				return fmt.Errorf("%s: %w", mutation, err)
+			}
+		}
+		return errValidated
+	})
+
+	if err == errValidated {
+		err = nil
+	}
+
+	return repinError(err, repin())
+}
+
+// applyMutation applies a single mutation within a write transaction.
+func applyMutation(tx *bbolt.Tx, mutation *Mutation) error {
+	key := []byte(mutation.Key)
+
+	if len(key) == 0 {
+		return errors.New("a key is required")
+	}
+
+	if len(mutation.Path) == 0 {
+		switch mutation.Op {
+		case MutationCreateBucket:
+			_, err := tx.CreateBucket(key)
+			return err
+		case MutationDeleteBucket:
+			return deleteBucket(tx.Cursor().Bucket(), key, mutation.Recursive, tx.DeleteBucket)
+		}
+
+		return errors.New("only buckets may be stored at the root of a db")
+	}
+
+	bucket := BucketAt(tx, mutation.Path)
+
+	if bucket == nil {
+		return fmt.Errorf("bucket not found: %s", mutation.Path)
+	}
+
+	switch mutation.Op {
+	case MutationSet:
+		if bucket.Bucket(key) != nil {
+			return errors.New("key is a bucket")
+		}
+		return bucket.Put(key, mutation.Value)
+	case MutationDelete:
+		if bucket.Bucket(key) != nil {
+			return errors.New("key is a bucket")
+		}
+		if bucket.Get(key) == nil {
+			return errors.New("key not found")
+		}
+		return bucket.Delete(key)
+	case MutationCreateBucket:
+		_, err := bucket.CreateBucket(key)
+		return err
+	case MutationDeleteBucket:
+		return deleteBucket(bucket, key, mutation.Recursive, bucket.DeleteBucket)
+	}
+
+	return fmt.Errorf("unknown operation: %s", mutation.Op)
+}
+
+// deleteBucket removes the child bucket `key` of `parent` with `del`. Buckets that are not empty are only removed if
+// `recursive` is true.
+func deleteBucket(parent *bbolt.Bucket, key []byte, recursive bool, del func([]byte) error) error {
+	child := parent.Bucket(key)
+
+	if child == nil {
+		return errors.New("bucket not found")
+	}
+
+	if !recursive {
+		if first, _ := child.Cursor().First(); first != nil {
+			return errors.New("bucket is not empty")
+		}
+	}
+
+	return del(key)
+}
+
+// backup copies the database file to BackupPath the first time it is called.
+func (state *State) backup() error {
+	if state.SkipBackup || state.BackupPath != "" {
+		return nil
+	}
+
+	backupPath := fmt.Sprintf("%s.backup-%s", state.DB.Path(), time.Now().Format("20060102-150405"))
+
+	err := state.View(func(tx *bbolt.Tx) error {
+		return tx.CopyFile(backupPath, 0600)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	state.BackupPath = backupPath
+	return nil
+}
@@ -0,0 +1,168 @@
+/*
+	Copyright NetFoundry, Inc.
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+	https://www.apache.org/licenses/LICENSE-2.0
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package zdelib
+
+import (
+	"github.com/openziti/storage/boltz"
+	"go.etcd.io/bbolt"
+	"testing"
+)
+
+// newTransactionTestState returns a State for a db with an empty `ziti` bucket that does not write backups.
+func newTransactionTestState(t *testing.T) *State {
+	t.Helper()
+
+	state := newTestState(t, func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucket([]byte("ziti"))
+		return err
+	})
+	state.SkipBackup = true
+
+	return state
+}
+
+// setMutation returns a Mutation setting `key` in the `ziti` bucket to the string `value`.
+func setMutation(t *testing.T, key, value string) *Mutation {
+	t.Helper()
+
+	encoded, err := EncodeValue(boltz.TypeString, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Mutation{Op: MutationSet, Path: []string{"ziti"}, Key: key, Value: encoded}
+}
+
+// storedKeys returns the keys stored in the `ziti` bucket.
+func storedKeys(t *testing.T, state *State) []string {
+	t.Helper()
+
+	var keys []string
+	err := state.DB.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte("ziti")).ForEach(func(key, _ []byte) error {
+			keys = append(keys, string(key))
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return keys
+}
+
+func TestTransactionCommit(t *testing.T) {
+	state := newTransactionTestState(t)
+
+	if err := state.Begin(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := state.Begin(); err == nil {
+		t.Error("expected an error beginning a second transaction")
+	}
+
+	if err := state.Stage(setMutation(t, "a", "1"), setMutation(t, "b", "2")); err != nil {
+		t.Fatal(err)
+	}
+
+	if !state.InTransaction() || len(state.Pending()) != 2 {
+		t.Fatalf("expected 2 staged changes, got %d", len(state.Pending()))
+	}
+
+	if keys := storedKeys(t, state); len(keys) != 0 {
+		t.Fatalf("staged changes should not be visible before commit, got %v", keys)
+	}
+
+	if err := state.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if state.InTransaction() || len(state.Pending()) != 0 {
+		t.Error("expected the transaction to end on commit")
+	}
+
+	if keys := storedKeys(t, state); len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Errorf("expected a and b to be committed, got %v", keys)
+	}
+}
+
+func TestTransactionRollback(t *testing.T) {
+	state := newTransactionTestState(t)
+
+	if err := state.Rollback(); err == nil {
+		t.Error("expected an error rolling back without a transaction")
+	}
+
+	if err := state.Begin(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := state.Stage(setMutation(t, "a", "1")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := state.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	if state.InTransaction() || len(state.Pending()) != 0 {
+		t.Error("expected the transaction to end on rollback")
+	}
+
+	if keys := storedKeys(t, state); len(keys) != 0 {
+		t.Errorf("expected rolled back changes to be discarded, got %v", keys)
+	}
+
+	if err := state.Commit(); err == nil {
+		t.Error("expected an error committing without a transaction")
+	}
+}
+
+func TestTransactionStageValidates(t *testing.T) {
+	state := newTransactionTestState(t)
+
+	if err := state.Begin(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := state.Stage(&Mutation{Op: MutationCreateBucket, Path: []string{"ziti"}, Key: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// creating the same bucket again only fails because of the change already staged
+	if err := state.Stage(&Mutation{Op: MutationCreateBucket, Path: []string{"ziti"}, Key: "a"}); err == nil {
+		t.Error("expected an error staging a change that conflicts with a staged change")
+	}
+
+	if err := state.Stage(&Mutation{Op: MutationDelete, Path: []string{"missing"}, Key: "a"}); err == nil {
+		t.Error("expected an error staging a change to a missing bucket")
+	}
+
+	if len(state.Pending()) != 1 {
+		t.Errorf("expected only the valid change to be staged, got %d", len(state.Pending()))
+	}
+}
+
+func TestStageWithoutTransactionApplies(t *testing.T) {
+	state := newTransactionTestState(t)
+
+	if err := state.Stage(setMutation(t, "a", "1")); err != nil {
+		t.Fatal(err)
+	}
+
+	if keys := storedKeys(t, state); len(keys) != 1 || keys[0] != "a" {
+		t.Errorf("expected the change to be applied immediately, got %v", keys)
+	}
+}